@@ -0,0 +1,464 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/mail"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/stats"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultDigestHour    = 21
+	digestTickInterval   = time.Minute
+	digestBatchSize      = 50
+	digestRunRetention   = 30 * 24 * time.Hour
+	digestMinStreak      = 3
+	digestDayLabelLayout = "Monday, January 2"
+)
+
+type DigestPoster struct {
+	Name   string
+	Photos int
+}
+
+type DigestHighlight struct {
+	Name      string
+	Caption   string
+	Reactions int
+	Comments  int
+}
+
+type DigestStreak struct {
+	Name         string
+	Streak       int
+	Ended        bool
+	PersonalBest bool
+}
+
+type DigestGroup struct {
+	Name      string
+	Posters   []DigestPoster
+	Highlight *DigestHighlight
+	Streaks   []DigestStreak
+}
+
+type Digest struct {
+	Name           string
+	Day            string
+	Groups         []DigestGroup
+	UnsubscribeURL string
+}
+
+// digestHour is the local hour, from DIGEST_HOUR, at which a user's digest goes out.
+// Each digest covers the 24 hours before it.
+func digestHour() int {
+	hour, err := strconv.Atoi(os.Getenv("DIGEST_HOUR"))
+	if err != nil || hour < 0 || hour > 23 {
+		return defaultDigestHour
+	}
+	return hour
+}
+
+// unsubscribeSecret signs unsubscribe links. It falls back to JWT_SECRET so links
+// work without extra configuration; main refuses to start with neither set.
+func unsubscribeSecret() []byte {
+	if secret := os.Getenv("UNSUBSCRIBE_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+func signUnsubscribe(userID string) string {
+	mac := hmac.New(sha256.New, unsubscribeSecret())
+	mac.Write([]byte("digest-unsubscribe:" + userID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// unsubscribeToken never expires, so links in old emails keep working.
+func unsubscribeToken(userID string) string {
+	return userID + "." + signUnsubscribe(userID)
+}
+
+// parseUnsubscribeToken returns the user the token was issued to.
+func parseUnsubscribeToken(token string) (string, bool) {
+	userID, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", false
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return "", false
+	}
+	if !hmac.Equal([]byte(signUnsubscribe(userID)), []byte(sig)) {
+		return "", false
+	}
+	return userID, true
+}
+
+func unsubscribeLink(userID string) string {
+	base := os.Getenv("EMAIL_LINK_BASE")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return strings.TrimSuffix(base, "/") + "/api/email/unsubscribe?token=" + url.QueryEscape(unsubscribeToken(userID))
+}
+
+// digestGroupActivity summarizes what the viewer could see of a group between start
+// and end. Photos follow the same audience rules as the slideshow.
+func digestGroupActivity(ctx context.Context, viewerID, groupID string, start, end, now time.Time) (DigestGroup, error) {
+	var g DigestGroup
+
+	rows, err := db.Query(ctx,
+		`SELECT COALESCE(NULLIF(u.name, ''), u.handle, 'Someone'), COUNT(*)
+		 FROM photos p
+		 JOIN group_members gm ON gm.user_id = p.user_id AND gm.group_id = $2
+		 JOIN users u ON u.id = p.user_id
+		 WHERE p.hour_timestamp >= $3 AND p.hour_timestamp < $4
+		 AND `+photoVisibleSQL("p", "gm.group_id", "$1::uuid")+`
+		 GROUP BY u.id, u.name, u.handle
+		 ORDER BY COUNT(*) DESC, 1`,
+		viewerID, groupID, start, end,
+	)
+	if err != nil {
+		return g, err
+	}
+	for rows.Next() {
+		var p DigestPoster
+		if err := rows.Scan(&p.Name, &p.Photos); err != nil {
+			continue
+		}
+		g.Posters = append(g.Posters, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return g, err
+	}
+
+	// the highlight is the photo with the most reactions and comments
+	var h DigestHighlight
+	err = db.QueryRow(ctx,
+		`SELECT COALESCE(NULLIF(u.name, ''), u.handle, 'Someone'), COALESCE(p.caption, ''), r.n, c.n
+		 FROM photos p
+		 JOIN group_members gm ON gm.user_id = p.user_id AND gm.group_id = $2
+		 JOIN users u ON u.id = p.user_id
+		 CROSS JOIN LATERAL (SELECT COUNT(*) AS n FROM photo_reactions WHERE photo_id = p.id) r
+		 CROSS JOIN LATERAL (SELECT COUNT(*) AS n FROM photo_comments WHERE photo_id = p.id) c
+		 WHERE p.hour_timestamp >= $3 AND p.hour_timestamp < $4
+		 AND r.n + c.n > 0
+		 AND `+photoVisibleSQL("p", "gm.group_id", "$1::uuid")+`
+		 ORDER BY r.n + c.n DESC, p.hour_timestamp DESC
+		 LIMIT 1`,
+		viewerID, groupID, start, end,
+	).Scan(&h.Name, &h.Caption, &h.Reactions, &h.Comments)
+	if err == nil {
+		g.Highlight = &h
+	} else if err != pgx.ErrNoRows {
+		return g, err
+	}
+
+	// streaks that were alive during the day, whether they are still going or ended
+	rows, err = db.Query(ctx,
+		`SELECT COALESCE(NULLIF(u.name, ''), u.handle, 'Someone'), s.current_streak, s.longest_streak, s.last_slot >= $4
+		 FROM group_members gm
+		 JOIN users u ON u.id = gm.user_id
+		 JOIN user_stats s ON s.user_id = gm.user_id
+		 WHERE gm.group_id = $2
+		 AND s.last_slot >= $3
+		 AND s.current_streak >= $5
+		 AND (gm.user_id = $1 OR `+notBlockedSQL("gm.user_id", "$1::uuid")+`)
+		 ORDER BY s.current_streak DESC, 1`,
+		viewerID, groupID, start, stats.StreakCutoff(now), digestMinStreak,
+	)
+	if err != nil {
+		return g, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s DigestStreak
+		var longest int
+		var alive bool
+		if err := rows.Scan(&s.Name, &s.Streak, &longest, &alive); err != nil {
+			continue
+		}
+		s.Ended = !alive
+		s.PersonalBest = alive && s.Streak == longest
+		g.Streaks = append(g.Streaks, s)
+	}
+	return g, rows.Err()
+}
+
+// buildDigest gathers every group of the user that had something to report.
+func buildDigest(ctx context.Context, userID string, start, end, now time.Time) ([]DigestGroup, error) {
+	rows, err := db.Query(ctx,
+		`SELECT g.id::text, g.name
+		 FROM group_members gm
+		 JOIN groups g ON g.id = gm.group_id
+		 WHERE gm.user_id = $1
+		 ORDER BY lower(g.name)`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	type group struct{ id, name string }
+	groups := make([]group, 0)
+	for rows.Next() {
+		var g group
+		if err := rows.Scan(&g.id, &g.name); err != nil {
+			continue
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	digest := make([]DigestGroup, 0, len(groups))
+	for _, g := range groups {
+		activity, err := digestGroupActivity(ctx, userID, g.id, start, end, now)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", g.id, err)
+		}
+		if len(activity.Posters) == 0 && len(activity.Streaks) == 0 {
+			continue
+		}
+		activity.Name = g.name
+		digest = append(digest, activity)
+	}
+	return digest, nil
+}
+
+var digestTextTemplate = texttemplate.Must(texttemplate.New("digest").Parse(
+	`Hi{{if .Name}} {{.Name}}{{end}}, here's how your groups did on {{.Day}}.
+{{range .Groups}}
+== {{.Name}} ==
+{{if .Posters}}Posted: {{range $i, $p := .Posters}}{{if $i}}, {{end}}{{$p.Name}} ({{$p.Photos}}){{end}}
+{{else}}Nobody posted.
+{{end}}{{with .Highlight}}Highlight: {{.Name}}'s snapshot{{if .Caption}} "{{.Caption}}"{{end}} got {{.Reactions}} reactions and {{.Comments}} comments
+{{end}}{{range .Streaks}}{{if .Ended}}{{.Name}}'s streak of {{.Streak}} ended
+{{else}}{{.Name}} is on a streak of {{.Streak}}{{if .PersonalBest}}, a personal best{{end}}
+{{end}}{{end}}{{end}}
+You're getting this because you turned on the daily digest.
+Unsubscribe: {{.UnsubscribeURL}}
+`))
+
+var digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest").Parse(
+	`<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; color: #111; max-width: 560px; margin: 0 auto; padding: 24px;">
+<p>Hi{{if .Name}} {{.Name}}{{end}}, here's how your groups did on {{.Day}}.</p>
+{{range .Groups}}
+<h2 style="font-size: 18px; margin: 24px 0 8px;">{{.Name}}</h2>
+{{if .Posters}}<p><strong>Posted:</strong> {{range $i, $p := .Posters}}{{if $i}}, {{end}}{{$p.Name}} ({{$p.Photos}}){{end}}</p>
+{{else}}<p>Nobody posted.</p>
+{{end}}{{with .Highlight}}<p><strong>Highlight:</strong> {{.Name}}'s snapshot{{if .Caption}} &ldquo;{{.Caption}}&rdquo;{{end}} got {{.Reactions}} reactions and {{.Comments}} comments</p>
+{{end}}{{if .Streaks}}<ul>
+{{range .Streaks}}<li>{{if .Ended}}{{.Name}}'s streak of {{.Streak}} ended{{else}}{{.Name}} is on a streak of {{.Streak}}{{if .PersonalBest}}, a personal best{{end}}{{end}}</li>
+{{end}}</ul>
+{{end}}{{end}}
+<p style="color: #666; font-size: 12px; margin-top: 32px;">You're getting this because you turned on the daily digest. <a href="{{.UnsubscribeURL}}">Unsubscribe</a></p>
+</body>
+</html>
+`))
+
+func renderDigest(d Digest) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := digestTextTemplate.Execute(&text, d); err != nil {
+		return "", "", err
+	}
+	if err := digestHTMLTemplate.Execute(&html, d); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+type digestRecipient struct {
+	userID   string
+	email    string
+	name     string
+	timezone string
+	day      time.Time
+}
+
+// dueDigests finds opted in users whose digest hour has passed today, in their own
+// timezone, and who haven't had today's digest yet. Users with a timezone Postgres
+// doesn't know are skipped rather than failing everyone's digest.
+func dueDigests(ctx context.Context, hour int) ([]digestRecipient, error) {
+	rows, err := db.Query(ctx,
+		`SELECT d.id::text, d.email, d.name, d.tz, d.local_now::date
+		 FROM (
+			SELECT u.id, u.email, COALESCE(NULLIF(u.name, ''), u.handle, '') AS name,
+				tzn.name AS tz,
+				NOW() AT TIME ZONE tzn.name AS local_now
+			FROM users u
+			JOIN notification_preferences np ON np.user_id = u.id
+			JOIN pg_timezone_names tzn ON tzn.name = COALESCE(u.timezone, 'UTC')
+			WHERE np.daily_digest
+		 ) d
+		 WHERE EXTRACT(HOUR FROM d.local_now) >= $1
+		 AND NOT EXISTS (
+			SELECT 1 FROM digest_runs dr
+			WHERE dr.user_id = d.id AND dr.day = d.local_now::date
+		 )
+		 LIMIT $2`,
+		hour, digestBatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	due := make([]digestRecipient, 0)
+	for rows.Next() {
+		var d digestRecipient
+		if err := rows.Scan(&d.userID, &d.email, &d.name, &d.timezone, &d.day); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// sendDigest claims the recipient's day and sends it. Only the instance that wins
+// the claim sends, and a failed send is recorded rather than retried so nobody gets
+// the same digest twice.
+func sendDigest(ctx context.Context, transport mail.Transport, instance string, hour int, d digestRecipient, now time.Time) error {
+	tag, err := db.Exec(ctx,
+		`INSERT INTO digest_runs (user_id, day, claimed_by) VALUES ($1, $2, $3)
+		 ON CONFLICT DO NOTHING`,
+		d.userID, d.day, instance,
+	)
+	if err != nil || tag.RowsAffected() == 0 {
+		return err
+	}
+
+	status, sendErr := deliverDigest(ctx, transport, hour, d, now)
+	var errText *string
+	if sendErr != nil {
+		status = "failed"
+		msg := sendErr.Error()
+		errText = &msg
+	}
+
+	_, err = db.Exec(ctx,
+		`UPDATE digest_runs SET status = $3, error = $4, finished_at = NOW()
+		 WHERE user_id = $1 AND day = $2`,
+		d.userID, d.day, status, errText,
+	)
+	if err != nil {
+		return err
+	}
+	return sendErr
+}
+
+func deliverDigest(ctx context.Context, transport mail.Transport, hour int, d digestRecipient, now time.Time) (string, error) {
+	loc, err := time.LoadLocation(d.timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	end := time.Date(d.day.Year(), d.day.Month(), d.day.Day(), hour, 0, 0, 0, loc)
+	start := end.AddDate(0, 0, -1)
+
+	groups, err := buildDigest(ctx, d.userID, start, end, now)
+	if err != nil {
+		return "", err
+	}
+	// a quiet day isn't worth an email
+	if len(groups) == 0 {
+		return "empty", nil
+	}
+
+	msg, err := digestMessage(d.email, Digest{
+		Name:           d.name,
+		Day:            end.Format(digestDayLabelLayout),
+		Groups:         groups,
+		UnsubscribeURL: unsubscribeLink(d.userID),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := transport.Send(ctx, msg); err != nil {
+		return "", err
+	}
+	return "sent", nil
+}
+
+// digestMessage renders the digest email, with the headers mail clients use to offer
+// one-click unsubscribe (RFC 8058).
+func digestMessage(to string, digest Digest) (mail.Message, error) {
+	text, html, err := renderDigest(digest)
+	if err != nil {
+		return mail.Message{}, err
+	}
+	return mail.Message{
+		To:      to,
+		Subject: "Your SNAPSHOT recap for " + digest.Day,
+		Text:    text,
+		HTML:    html,
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + digest.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}, nil
+}
+
+// runDigestScheduler sends due digests every minute until ctx ends. Any number of
+// instances can run it since each digest is claimed in digest_runs first.
+func runDigestScheduler(ctx context.Context, transport mail.Transport) {
+	// claiming days without sending would mark digests sent that never went out
+	if _, disabled := transport.(mail.DisabledTransport); disabled {
+		log.Println("Daily digests are off: no mail transport is configured")
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	id, _ := uuid.NewV7()
+	instance := hostname + "/" + id.String()
+	hour := digestHour()
+
+	ticker := time.NewTicker(digestTickInterval)
+	defer ticker.Stop()
+
+	lastPrune := time.Time{}
+	for {
+		now := time.Now()
+		due, err := dueDigests(ctx, hour)
+		if err != nil {
+			log.Printf("Digest lookup failed: %v", err)
+		}
+		for _, d := range due {
+			if err := sendDigest(ctx, transport, instance, hour, d, now); err != nil {
+				log.Printf("Digest for %s failed: %v", d.userID, err)
+			}
+		}
+
+		if now.Sub(lastPrune) > time.Hour {
+			_, err := db.Exec(ctx, "DELETE FROM digest_runs WHERE claimed_at < $1", now.Add(-digestRunRetention))
+			if err != nil {
+				log.Printf("Digest prune failed: %v", err)
+			}
+			lastPrune = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
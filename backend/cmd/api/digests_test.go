@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/mail"
+)
+
+func TestDigestThroughMemoryTransport(t *testing.T) {
+	t.Setenv("UNSUBSCRIBE_SECRET", "test-secret")
+	t.Setenv("EMAIL_LINK_BASE", "https://snapshot.example/")
+
+	userID := "0190f0c2-7b1e-7c3a-9d5e-2f4a6b8c0d1e"
+	msg, err := digestMessage("ada@example.com", Digest{
+		Name: "Ada",
+		Day:  "Monday, October 19",
+		Groups: []DigestGroup{{
+			Name:      "Climbing <crew>",
+			Posters:   []DigestPoster{{Name: "Grace", Photos: 2}},
+			Highlight: &DigestHighlight{Name: "Grace", Caption: "summit", Reactions: 4, Comments: 1},
+			Streaks:   []DigestStreak{{Name: "Grace", Streak: 5, PersonalBest: true}},
+		}},
+		UnsubscribeURL: unsubscribeLink(userID),
+	})
+	if err != nil {
+		t.Fatalf("digestMessage: %v", err)
+	}
+
+	transport := mail.NewMemoryTransport()
+	if err := transport.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	sent := transport.Messages()
+	if len(sent) != 1 {
+		t.Fatalf("got %d messages, want 1", len(sent))
+	}
+	got := sent[0]
+
+	if got.To != "ada@example.com" || got.Subject != "Your SNAPSHOT recap for Monday, October 19" {
+		t.Errorf("got To %q Subject %q", got.To, got.Subject)
+	}
+	for _, want := range []string{"Hi Ada", "Climbing <crew>", "Grace (2)", `"summit" got 4 reactions`, "streak of 5, a personal best"} {
+		if !strings.Contains(got.Text, want) {
+			t.Errorf("text body is missing %q:\n%s", want, got.Text)
+		}
+	}
+	if !strings.Contains(got.HTML, "Climbing &lt;crew&gt;") {
+		t.Errorf("html body doesn't escape the group name:\n%s", got.HTML)
+	}
+	if got.Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q", got.Headers["List-Unsubscribe-Post"])
+	}
+
+	link := strings.TrimSuffix(strings.TrimPrefix(got.Headers["List-Unsubscribe"], "<"), ">")
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("List-Unsubscribe %q: %v", link, err)
+	}
+	if u.Host != "snapshot.example" || u.Path != "/api/email/unsubscribe" {
+		t.Errorf("unsubscribe link %q points elsewhere", link)
+	}
+	token := u.Query().Get("token")
+	if parsed, ok := parseUnsubscribeToken(token); !ok || parsed != userID {
+		t.Errorf("unsubscribe token parsed to %q, %v", parsed, ok)
+	}
+	if _, ok := parseUnsubscribeToken(token + "x"); ok {
+		t.Error("tampered unsubscribe token was accepted")
+	}
+}
+
+func TestMemoryTransportKeepsRecentMessages(t *testing.T) {
+	transport := mail.NewMemoryTransport()
+	for i := range 250 {
+		if err := transport.Send(context.Background(), mail.Message{Subject: strconv.Itoa(i)}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	sent := transport.Messages()
+	if len(sent) != 100 {
+		t.Fatalf("holding %d messages, want 100", len(sent))
+	}
+	if sent[0].Subject != "150" || sent[len(sent)-1].Subject != "249" {
+		t.Errorf("holding %s to %s, want the 100 most recent", sent[0].Subject, sent[len(sent)-1].Subject)
+	}
+}
+
+func TestDisabledTransportRefuses(t *testing.T) {
+	t.Setenv("MAIL_TRANSPORT", "")
+	transport := mail.NewTransportFromEnv()
+	if err := transport.Send(context.Background(), mail.Message{To: "ada@example.com"}); err != mail.ErrDisabled {
+		t.Errorf("Send returned %v, want ErrDisabled", err)
+	}
+}
+
+func TestUnsubscribeGetOnlyConfirms(t *testing.T) {
+	t.Setenv("UNSUBSCRIBE_SECRET", "test-secret")
+	token := unsubscribeToken("0190f0c2-7b1e-7c3a-9d5e-2f4a6b8c0d1e")
+
+	// db is nil here, so a GET that tried to unsubscribe would panic
+	req := httptest.NewRequest(http.MethodGet, "/api/email/unsubscribe?token="+url.QueryEscape(token), nil)
+	rec := httptest.NewRecorder()
+	handleUnsubscribeDigest(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `<form method="post"`) || !strings.Contains(body, url.QueryEscape(token)) {
+		t.Errorf("confirmation page has no form posting the token:\n%s", body)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+const (
+	zeroWidthJoiner = '\u200d'
+	variationEmoji  = '\ufe0f'
+	keycapMark      = '\u20e3'
+	tagCancel       = '\U000e007f'
+)
+
+// pictographRanges are the code points that render as an emoji on their own or with a
+// variation selector. Regional indicators and skin tone modifiers are handled apart.
+var pictographRanges = [][2]rune{
+	{0x00a9, 0x00a9}, {0x00ae, 0x00ae}, {0x203c, 0x203c}, {0x2049, 0x2049},
+	{0x2122, 0x2122}, {0x2139, 0x2139}, {0x2194, 0x2199}, {0x21a9, 0x21aa},
+	{0x231a, 0x231b}, {0x2328, 0x2328}, {0x23cf, 0x23cf}, {0x23e9, 0x23f3},
+	{0x23f8, 0x23fa}, {0x24c2, 0x24c2}, {0x25aa, 0x25ab}, {0x25b6, 0x25b6},
+	{0x25c0, 0x25c0}, {0x25fb, 0x25fe}, {0x2600, 0x27bf}, {0x2934, 0x2935},
+	{0x2b05, 0x2b07}, {0x2b1b, 0x2b1c}, {0x2b50, 0x2b50}, {0x2b55, 0x2b55},
+	{0x3030, 0x3030}, {0x303d, 0x303d}, {0x3297, 0x3297}, {0x3299, 0x3299},
+	{0x1f000, 0x1faff},
+}
+
+func isPictograph(r rune) bool {
+	if isRegionalIndicator(r) || isSkinTone(r) {
+		return false
+	}
+	for _, rg := range pictographRanges {
+		if r >= rg[0] && r <= rg[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool { return r >= 0x1f1e6 && r <= 0x1f1ff }
+func isSkinTone(r rune) bool          { return r >= 0x1f3fb && r <= 0x1f3ff }
+func isTag(r rune) bool               { return r >= 0xe0020 && r <= 0xe007e }
+
+// isEmoji reports whether s is exactly one emoji: a pictograph with its modifiers, a
+// ZWJ sequence of those, a flag or a keycap.
+func isEmoji(s string) bool {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return false
+	}
+
+	// flags are a pair of regional indicators
+	if isRegionalIndicator(runes[0]) {
+		return len(runes) == 2 && isRegionalIndicator(runes[1])
+	}
+
+	// keycaps are a digit, # or * with an optional variation selector and the keycap mark
+	if r := runes[0]; r == '#' || r == '*' || (r >= '0' && r <= '9') {
+		rest := runes[1:]
+		if len(rest) > 0 && rest[0] == variationEmoji {
+			rest = rest[1:]
+		}
+		return len(rest) == 1 && rest[0] == keycapMark
+	}
+
+	i := 0
+	for {
+		if i >= len(runes) || !isPictograph(runes[i]) {
+			return false
+		}
+		i++
+		if i < len(runes) && runes[i] == variationEmoji {
+			i++
+		}
+		if i < len(runes) && isSkinTone(runes[i]) {
+			i++
+		}
+		// subdivision flags spell their region in tag characters
+		if i < len(runes) && isTag(runes[i]) {
+			for i < len(runes) && isTag(runes[i]) {
+				i++
+			}
+			if i >= len(runes) || runes[i] != tagCancel {
+				return false
+			}
+			i++
+		}
+
+		if i == len(runes) {
+			return true
+		}
+		if runes[i] != zeroWidthJoiner {
+			return false
+		}
+		i++
+	}
+}
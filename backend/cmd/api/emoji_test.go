@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestIsEmoji(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want bool
+	}{
+		{"pictograph", "🔥", true},
+		{"with variation selector", "❤️", true},
+		{"without variation selector", "❤", true},
+		{"skin tone", "👍🏽", true},
+		{"zwj sequence", "👩‍💻", true},
+		{"zwj with skin tone", "🧑🏿‍🚀", true},
+		{"flag", "🇯🇵", true},
+		{"subdivision flag", "🏴\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f", true},
+		{"keycap", "1️⃣", true},
+		{"keycap without selector", "#⃣", true},
+		{"empty", "", false},
+		{"text", "lol", false},
+		{"emoji then text", "🔥a", false},
+		{"two emoji", "🔥🔥", false},
+		{"lone regional indicator", "🇯", false},
+		{"three regional indicators", "🇯🇵🇺", false},
+		{"lone skin tone", "🏽", false},
+		{"trailing joiner", "👩‍", false},
+		{"digit", "1", false},
+		{"unterminated tags", "🏴\U000e0067\U000e0062", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isEmoji(tt.in); got != tt.want {
+				t.Errorf("isEmoji(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
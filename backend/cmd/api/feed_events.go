@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/realtime"
+)
+
+const (
+	EventPhotoPosted   = "photo_posted"
+	EventReactionAdded = "reaction_added"
+	EventCommentAdded  = "comment_added"
+	EventMemberJoined  = "member_joined"
+	EventMemberLeft    = "member_left"
+
+	feedEventRetention     = 24 * time.Hour
+	feedEventPruneInterval = time.Hour
+	// feedEventGapWait is how long a missing ID is waited for before it is assumed to
+	// belong to a rolled back transaction
+	feedEventGapWait = 5 * time.Second
+)
+
+var feedHub *realtime.Hub
+
+type FeedEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	ActorID   string          `json:"actor_id"`
+	GroupID   *string         `json:"group_id"`
+	PhotoID   *string         `json:"photo_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// recordFeedEvent stores an event for live streams and wakes every instance's
+// listener. Inside a transaction both only take effect when it commits.
+func recordFeedEvent(ctx context.Context, q dbtx, eventType, actorID string, groupID, photoID *string, data map[string]any) error {
+	if data == nil {
+		data = map[string]any{}
+	}
+	_, err := q.Exec(ctx,
+		`WITH inserted AS (
+			INSERT INTO feed_events (type, actor_id, group_id, photo_id, data)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		 )
+		 SELECT pg_notify($6, id::text) FROM inserted`,
+		eventType, actorID, groupID, photoID, data, realtime.Channel,
+	)
+	return err
+}
+
+// logFeedEvent records an event outside a transaction, where a failure should not
+// undo a write that already happened.
+func logFeedEvent(ctx context.Context, eventType, actorID string, groupID, photoID *string, data map[string]any) {
+	if err := recordFeedEvent(ctx, db, eventType, actorID, groupID, photoID, data); err != nil {
+		log.Printf("Failed to record %s event: %v", eventType, err)
+	}
+}
+
+// latestFeedEventID is where a stream without a Last-Event-ID starts.
+func latestFeedEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := db.QueryRow(ctx, "SELECT COALESCE(MAX(id), 0) FROM feed_events").Scan(&id)
+	return id, err
+}
+
+// canResumeFeed reports whether a stream can pick up after afterID. It can't when
+// the events after it were already deleted, or when afterID was never issued, as
+// with an ID cached from before a database restore.
+func canResumeFeed(ctx context.Context, afterID int64) (bool, error) {
+	// with nothing left to compare against, anything before the last issued ID is gone
+	var resumable bool
+	err := db.QueryRow(ctx,
+		`SELECT $1 >= COALESCE((SELECT MIN(id) - 1 FROM feed_events), s.issued) AND $1 <= s.issued
+		 FROM (SELECT CASE WHEN is_called THEN last_value ELSE 0 END AS issued FROM feed_events_id_seq) s`,
+		afterID,
+	).Scan(&resumable)
+	return resumable, err
+}
+
+// loadFeedEvents returns the events after afterID that userID may see, and the ID
+// the stream has now read up to. Membership events go to current members of the
+// group; photo events go to anyone who can see the photo. Events by users blocked
+// by or blocking the viewer are skipped.
+//
+// IDs are handed out when a transaction inserts but become visible when it commits,
+// so a young gap may still fill in. A gap is as old as the event after it, whose
+// created_at is when it was inserted rather than when its transaction began. Reading
+// stops before a young gap and waiting reports true so the caller can look again
+// shortly.
+func loadFeedEvents(ctx context.Context, userID string, afterID int64, limit int) ([]FeedEvent, int64, bool, error) {
+	rows, err := db.Query(ctx,
+		`SELECT e.id, e.type, e.actor_id::text, e.group_id::text, e.photo_id::text, e.data, e.created_at,
+			e.created_at > clock_timestamp() - $4 * INTERVAL '1 second',
+			(e.actor_id = $1 OR `+notBlockedSQL("e.actor_id", "$1::uuid")+`)
+			AND CASE
+				WHEN e.photo_id IS NOT NULL THEN EXISTS (
+					SELECT 1 FROM photos p
+					WHERE p.id = e.photo_id
+					AND `+viewablePhotoSQL("p", "$1::uuid")+`
+				)
+				ELSE EXISTS (
+					SELECT 1 FROM group_members gm
+					WHERE gm.group_id = e.group_id AND gm.user_id = $1
+				)
+			END
+		 FROM feed_events e
+		 WHERE e.id > $2
+		 ORDER BY e.id
+		 LIMIT $3`,
+		userID, afterID, limit, feedEventGapWait.Seconds(),
+	)
+	if err != nil {
+		return nil, afterID, false, err
+	}
+	defer rows.Close()
+
+	loaded := make([]loadedFeedEvent, 0)
+	for rows.Next() {
+		var l loadedFeedEvent
+		e := &l.FeedEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.ActorID, &e.GroupID, &e.PhotoID, &e.Data, &e.CreatedAt, &l.young, &l.visible); err != nil {
+			return nil, afterID, false, err
+		}
+		loaded = append(loaded, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, afterID, false, err
+	}
+
+	events, afterID, waiting := readFeedEvents(afterID, loaded)
+	return events, afterID, waiting, nil
+}
+
+// loadedFeedEvent is an event as loadFeedEvents reads it, before gaps are checked.
+type loadedFeedEvent struct {
+	FeedEvent
+	// young events were inserted within feedEventGapWait
+	young   bool
+	visible bool
+}
+
+// readFeedEvents walks loaded, which is ordered by ID, keeping the visible events up
+// to the first young gap after afterID. Old gaps are skipped for good.
+func readFeedEvents(afterID int64, loaded []loadedFeedEvent) ([]FeedEvent, int64, bool) {
+	events := make([]FeedEvent, 0, len(loaded))
+	for _, l := range loaded {
+		if l.ID != afterID+1 && l.young {
+			return events, afterID, true
+		}
+		afterID = l.ID
+		if l.visible {
+			events = append(events, l.FeedEvent)
+		}
+	}
+	return events, afterID, false
+}
+
+// runFeedEventPruner deletes events too old to resume from, hourly until ctx ends.
+func runFeedEventPruner(ctx context.Context) {
+	ticker := time.NewTicker(feedEventPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := db.Exec(ctx,
+			"DELETE FROM feed_events WHERE created_at < $1",
+			time.Now().Add(-feedEventRetention),
+		)
+		if err != nil {
+			log.Printf("Feed event prune failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
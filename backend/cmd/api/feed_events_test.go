@@ -0,0 +1,42 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestReadFeedEvents(t *testing.T) {
+	event := func(id int64, young, visible bool) loadedFeedEvent {
+		return loadedFeedEvent{FeedEvent: FeedEvent{ID: id}, young: young, visible: visible}
+	}
+
+	tests := []struct {
+		name        string
+		afterID     int64
+		loaded      []loadedFeedEvent
+		wantIDs     []int64
+		wantAfterID int64
+		wantWaiting bool
+	}{
+		{"nothing new", 10, nil, []int64{}, 10, false},
+		{"contiguous", 10, []loadedFeedEvent{event(11, true, true), event(12, true, true)}, []int64{11, 12}, 12, false},
+		{"hidden events still advance", 10, []loadedFeedEvent{event(11, false, false), event(12, false, true)}, []int64{12}, 12, false},
+		{"young gap waits", 10, []loadedFeedEvent{event(11, true, true), event(13, true, true)}, []int64{11}, 11, true},
+		{"young gap at the start", 10, []loadedFeedEvent{event(12, true, true)}, []int64{}, 10, true},
+		{"old gap is skipped", 10, []loadedFeedEvent{event(11, false, true), event(14, false, true)}, []int64{11, 14}, 14, false},
+		{"old gap then young gap", 10, []loadedFeedEvent{event(13, false, true), event(15, true, true)}, []int64{13}, 13, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, afterID, waiting := readFeedEvents(tt.afterID, tt.loaded)
+			ids := make([]int64, 0, len(events))
+			for _, e := range events {
+				ids = append(ids, e.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) || afterID != tt.wantAfterID || waiting != tt.wantWaiting {
+				t.Errorf("got events %v, after %d, waiting %v; want %v, %d, %v",
+					ids, afterID, waiting, tt.wantIDs, tt.wantAfterID, tt.wantWaiting)
+			}
+		})
+	}
+}
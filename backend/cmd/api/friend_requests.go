@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	maxFriendRequestMessageLength = 140
+	defaultFriendRequestExpiry    = 30 * 24 * time.Hour
+	defaultFriendRequestCooldown  = 7 * 24 * time.Hour
+	friendRequestSweepInterval    = time.Hour
+)
+
+// envHours reads a whole number of hours from the environment, falling back to def
+// when unset or invalid.
+func envHours(name string, def time.Duration) time.Duration {
+	hours, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || hours <= 0 {
+		return def
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// friendRequestExpiry is how long a request stays pending, from FRIEND_REQUEST_EXPIRY_HOURS.
+func friendRequestExpiry() time.Duration {
+	return envHours("FRIEND_REQUEST_EXPIRY_HOURS", defaultFriendRequestExpiry)
+}
+
+// friendRequestCooldown is how long after a rejection the same user may ask again,
+// from FRIEND_REQUEST_COOLDOWN_HOURS.
+func friendRequestCooldown() time.Duration {
+	return envHours("FRIEND_REQUEST_COOLDOWN_HOURS", defaultFriendRequestCooldown)
+}
+
+// mutualFriendCountSQL counts users who are accepted friends of both arguments, given
+// as SQL expressions.
+func mutualFriendCountSQL(userID, otherID string) string {
+	return fmt.Sprintf(`(SELECT COUNT(*) FROM (
+		SELECT CASE WHEN mf.user_a_id = %[1]s THEN mf.user_b_id ELSE mf.user_a_id END
+		FROM friendships mf
+		WHERE (mf.user_a_id = %[1]s OR mf.user_b_id = %[1]s) AND mf.status = 'accepted'
+		INTERSECT
+		SELECT CASE WHEN mf.user_a_id = %[2]s THEN mf.user_b_id ELSE mf.user_a_id END
+		FROM friendships mf
+		WHERE (mf.user_a_id = %[2]s OR mf.user_b_id = %[2]s) AND mf.status = 'accepted'
+	) mutual)`, userID, otherID)
+}
+
+// sweepFriendRequests deletes pending requests past their expiry and rejections whose
+// cooldown has ended.
+func sweepFriendRequests(ctx context.Context) error {
+	expired, err := db.Exec(ctx,
+		"DELETE FROM friendships WHERE status = 'pending' AND created_at < $1",
+		time.Now().Add(-friendRequestExpiry()),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx,
+		"DELETE FROM friend_request_rejections WHERE rejected_at < $1",
+		time.Now().Add(-friendRequestCooldown()),
+	)
+	if err != nil {
+		return err
+	}
+
+	if n := expired.RowsAffected(); n > 0 {
+		log.Printf("Expired %d friend requests", n)
+	}
+	return nil
+}
+
+// runFriendRequestSweeper sweeps once at startup and then every hour until ctx ends.
+func runFriendRequestSweeper(ctx context.Context) {
+	ticker := time.NewTicker(friendRequestSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := sweepFriendRequests(ctx); err != nil {
+			log.Printf("Friend request sweep failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+var roleRank = map[string]int{
+	RoleMember: 1,
+	RoleAdmin:  2,
+	RoleOwner:  3,
+}
+
+// dbtx is satisfied by both the pool and a transaction.
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// getGroupRole returns the user's role in the group, or "" if they are not a member.
+func getGroupRole(ctx context.Context, q dbtx, groupID, userID string) (string, error) {
+	var role string
+	err := q.QueryRow(ctx,
+		"SELECT role FROM group_members WHERE group_id::text = $1 AND user_id::text = $2",
+		groupID, userID,
+	).Scan(&role)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// hasGroupRole reports whether role is at least min.
+func hasGroupRole(role, min string) bool {
+	return role != "" && roleRank[role] >= roleRank[min]
+}
+
+// canManageGroup reports whether the user may administer the group's invites and members.
+func canManageGroup(ctx context.Context, groupID, userID string) (bool, error) {
+	role, err := getGroupRole(ctx, db, groupID, userID)
+	return hasGroupRole(role, RoleAdmin), err
+}
+
+// requireGroupRole authenticates the caller and checks they hold at least min in the group.
+// It writes the error response itself and returns false when the request should stop.
+func requireGroupRole(w http.ResponseWriter, r *http.Request, groupID, min string) (string, string, bool) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", "", false
+	}
+
+	if groupID == "" {
+		http.Error(w, "group_id is required", http.StatusBadRequest)
+		return "", "", false
+	}
+
+	role, err := getGroupRole(r.Context(), db, groupID, userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return "", "", false
+	}
+	if role == "" {
+		http.Error(w, "Forbidden: You are not a member of this group", http.StatusForbidden)
+		return "", "", false
+	}
+	if !hasGroupRole(role, min) {
+		http.Error(w, "Forbidden: requires group "+min, http.StatusForbidden)
+		return "", "", false
+	}
+
+	return userID, role, true
+}
@@ -49,6 +49,9 @@ func handleCallback(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Error creating user: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if _, err := ensureCloseFriendsList(context.Background(), db, userID); err != nil {
+			log.Printf("Failed to create close friends list: %v", err)
+		}
 	} else if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
@@ -190,6 +193,9 @@ func handleGoogleLogin(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
+	if _, err := ensureCloseFriendsList(r.Context(), db, userID); err != nil {
+		log.Printf("Failed to create close friends list: %v", err)
+	}
 
 	token, err := auth.GenerateToken(auth.TokenPayload{
 		UserID:  userID,
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+)
+
+// Blocks reuse the pair's single friendships row: status 'blocked' with requester_id
+// set to the blocker. Blocking overwrites any friendship or pending request.
+
+// isBlocked reports whether either user has blocked the other.
+func isBlocked(ctx context.Context, q dbtx, userID, otherID string) (bool, error) {
+	status, _, err := getFriendshipStatus(ctx, q, userID, otherID)
+	return status == "blocked", err
+}
+
+// notBlockedSQL is a SQL condition that holds when neither user has blocked the other.
+func notBlockedSQL(userID, otherID string) string {
+	return fmt.Sprintf(`NOT EXISTS (
+		SELECT 1 FROM friendships bf
+		WHERE bf.user_a_id = LEAST(%[1]s, %[2]s)
+		AND bf.user_b_id = GREATEST(%[1]s, %[2]s)
+		AND bf.status = 'blocked'
+	)`, userID, otherID)
+}
+
+type BlockInput struct {
+	TargetID string `json:"target_id"`
+}
+
+func decodeBlockInput(w http.ResponseWriter, r *http.Request) (string, BlockInput, bool) {
+	var req BlockInput
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return "", req, false
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", req, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return "", req, false
+	}
+	req.TargetID = strings.ToLower(strings.TrimSpace(req.TargetID))
+	if req.TargetID == "" {
+		http.Error(w, "target_id is required", http.StatusBadRequest)
+		return "", req, false
+	}
+	if req.TargetID == userID {
+		http.Error(w, "You cannot block yourself", http.StatusBadRequest)
+		return "", req, false
+	}
+
+	return userID, req, true
+}
+
+func handleBlockUser(w http.ResponseWriter, r *http.Request) {
+	blockerID, req, ok := decodeBlockInput(w, r)
+	if !ok {
+		return
+	}
+
+	var exists bool
+	err := db.QueryRow(r.Context(),
+		"SELECT EXISTS(SELECT 1 FROM users WHERE id::text = $1)",
+		req.TargetID,
+	).Scan(&exists)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	userA, userB := blockerID, req.TargetID
+	if blockerID > req.TargetID {
+		userA, userB = req.TargetID, blockerID
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	// replaces any friendship or pending request. An existing block by either side is
+	// kept as is so the other user cannot lift it by blocking and unblocking.
+	_, err = tx.Exec(r.Context(),
+		`INSERT INTO friendships (user_a_id, user_b_id, status, requester_id)
+		 VALUES ($1, $2, 'blocked', $3)
+		 ON CONFLICT (user_a_id, user_b_id) DO UPDATE
+		 SET status = 'blocked', requester_id = EXCLUDED.requester_id, message = NULL
+		 WHERE friendships.status <> 'blocked'`,
+		userA, userB, blockerID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := removeFromFriendLists(r.Context(), tx, userA, userB); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// outstanding direct invitations between the pair can no longer be used
+	_, err = tx.Exec(r.Context(),
+		`UPDATE group_invites SET revoked_at = NOW()
+		 WHERE revoked_at IS NULL
+		 AND ((created_by = $1 AND invitee_id = $2) OR (created_by = $2 AND invitee_id = $1))`,
+		userA, userB,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// nothing either of them did should stay in the other's inbox
+	_, err = tx.Exec(r.Context(),
+		`DELETE FROM notifications
+		 WHERE (user_id = $1 AND actor_id = $2) OR (user_id = $2 AND actor_id = $1)`,
+		userA, userB,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":    "user_blocked",
+		"target_id": req.TargetID,
+	})
+}
+
+func handleUnblockUser(w http.ResponseWriter, r *http.Request) {
+	blockerID, req, ok := decodeBlockInput(w, r)
+	if !ok {
+		return
+	}
+
+	userA, userB := blockerID, req.TargetID
+	if blockerID > req.TargetID {
+		userA, userB = req.TargetID, blockerID
+	}
+
+	// only the user who placed the block may lift it
+	commandTag, err := db.Exec(r.Context(),
+		`DELETE FROM friendships
+		 WHERE user_a_id::text = $1 AND user_b_id::text = $2
+		 AND status = 'blocked'
+		 AND requester_id::text = $3`,
+		userA, userB, blockerID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		http.Error(w, "You have not blocked this user", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":    "user_unblocked",
+		"target_id": req.TargetID,
+	})
+}
+
+func handleListBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// only blocks the caller placed, never who has blocked them
+	rows, err := db.Query(r.Context(),
+		`SELECT u.id, COALESCE(u.name, ''), COALESCE(u.picture, '')
+		 FROM friendships f
+		 JOIN users u ON u.id = CASE
+			WHEN f.user_a_id = $1 THEN f.user_b_id
+			ELSE f.user_a_id
+		 END
+		 WHERE (f.user_a_id = $1 OR f.user_b_id = $1)
+		 AND f.status = 'blocked'
+		 AND f.requester_id = $1
+		 ORDER BY lower(u.name) ASC`,
+		userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	blocked := make([]Friend, 0)
+	for rows.Next() {
+		var f Friend
+		if err := rows.Scan(&f.ID, &f.Name, &f.Picture); err != nil {
+			continue
+		}
+		blocked = append(blocked, f)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"blocked": blocked,
+	})
+}
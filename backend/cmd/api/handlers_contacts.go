@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/ratelimit"
+)
+
+// Contact matching compares hashes only. Clients normalize each identifier, hash it
+// with the shared salt and upload the hashes; the server keeps hashes for users who
+// opted in and never stores what was uploaded.
+
+const maxContactHashesPerRequest = 1000
+
+// contactMatchLimiter caps match requests so the hash space can't be enumerated.
+var contactMatchLimiter = ratelimit.New(10, time.Hour)
+
+var (
+	phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	hashPattern  = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// contactHashSalt comes from CONTACT_HASH_SALT. Changing it invalidates every stored
+// hash until users save their discoverability settings again.
+func contactHashSalt() string {
+	return os.Getenv("CONTACT_HASH_SALT")
+}
+
+// hashContactIdentifier returns hex(sha256(salt + ":" + identifier)) for an already
+// normalized identifier, the same computation clients perform.
+func hashContactIdentifier(salt, identifier string) string {
+	sum := sha256.Sum256([]byte(salt + ":" + identifier))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// normalizePhone strips formatting and requires E.164, e.g. +14155550123.
+func normalizePhone(phone string) (string, bool) {
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, phone)
+	return phone, phonePattern.MatchString(phone)
+}
+
+type DiscoverabilitySettings struct {
+	DiscoverableByEmail bool   `json:"discoverable_by_email"`
+	DiscoverableByPhone bool   `json:"discoverable_by_phone"`
+	HasPhone            bool   `json:"has_phone"`
+	Salt                string `json:"salt"`
+}
+
+func getDiscoverability(ctx context.Context, userID string) (DiscoverabilitySettings, error) {
+	s := DiscoverabilitySettings{Salt: contactHashSalt()}
+	err := db.QueryRow(ctx,
+		`SELECT u.discoverable_by_email, u.discoverable_by_phone,
+			EXISTS(SELECT 1 FROM user_contact_hashes h WHERE h.user_id = u.id AND h.kind = 'phone')
+		 FROM users u WHERE u.id = $1`,
+		userID,
+	).Scan(&s.DiscoverableByEmail, &s.DiscoverableByPhone, &s.HasPhone)
+	return s, err
+}
+
+func handleDiscoverability(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleGetDiscoverability(w, r)
+	case http.MethodPatch:
+		handleUpdateDiscoverability(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleGetDiscoverability(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	settings, err := getDiscoverability(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdateDiscoverabilityRequest changes only the fields that are present. An empty
+// phone removes the stored phone hash.
+type UpdateDiscoverabilityRequest struct {
+	DiscoverableByEmail *bool   `json:"discoverable_by_email"`
+	DiscoverableByPhone *bool   `json:"discoverable_by_phone"`
+	Phone               *string `json:"phone"`
+}
+
+func handleUpdateDiscoverability(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	salt := contactHashSalt()
+	if salt == "" {
+		http.Error(w, "Contact matching is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req UpdateDiscoverabilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	var phoneHash string
+	if req.Phone != nil && strings.TrimSpace(*req.Phone) != "" {
+		phone, valid := normalizePhone(*req.Phone)
+		if !valid {
+			http.Error(w, "Phone must be in international format, e.g. +14155550123", http.StatusBadRequest)
+			return
+		}
+		phoneHash = hashContactIdentifier(salt, phone)
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	var email string
+	err = tx.QueryRow(r.Context(),
+		`UPDATE users SET
+			discoverable_by_email = COALESCE($2, discoverable_by_email),
+			discoverable_by_phone = COALESCE($3, discoverable_by_phone)
+		 WHERE id = $1
+		 RETURNING email`,
+		userID, req.DiscoverableByEmail, req.DiscoverableByPhone,
+	).Scan(&email)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// the email hash is always refreshed so it follows salt changes
+	_, err = tx.Exec(r.Context(),
+		`INSERT INTO user_contact_hashes (user_id, kind, hash) VALUES ($1, 'email', $2)
+		 ON CONFLICT (user_id, kind) DO UPDATE SET hash = EXCLUDED.hash`,
+		userID, hashContactIdentifier(salt, normalizeEmail(email)),
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Phone != nil {
+		if phoneHash == "" {
+			_, err = tx.Exec(r.Context(),
+				"DELETE FROM user_contact_hashes WHERE user_id = $1 AND kind = 'phone'",
+				userID,
+			)
+		} else {
+			_, err = tx.Exec(r.Context(),
+				`INSERT INTO user_contact_hashes (user_id, kind, hash) VALUES ($1, 'phone', $2)
+				 ON CONFLICT (user_id, kind) DO UPDATE SET hash = EXCLUDED.hash`,
+				userID, phoneHash,
+			)
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	settings, err := getDiscoverability(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+type ContactMatchRequest struct {
+	Hashes []string `json:"hashes"`
+}
+
+type ContactMatch struct {
+	Hash         string  `json:"hash"`
+	ID           string  `json:"id"`
+	Name         string  `json:"name"`
+	Handle       *string `json:"handle"`
+	Picture      *string `json:"picture"`
+	Relationship string  `json:"relationship"`
+}
+
+func handleMatchContacts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if contactHashSalt() == "" {
+		http.Error(w, "Contact matching is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	if allowed, retryAfter := contactMatchLimiter.Allow(userID); !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "Too many contact uploads, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	var req ContactMatchRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Hashes) == 0 {
+		http.Error(w, "hashes is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Hashes) > maxContactHashesPerRequest {
+		http.Error(w, "Too many hashes (max 1000 per request)", http.StatusBadRequest)
+		return
+	}
+
+	hashes := make([]string, 0, len(req.Hashes))
+	for _, h := range req.Hashes {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !hashPattern.MatchString(h) {
+			http.Error(w, "Hashes must be hex-encoded SHA-256", http.StatusBadRequest)
+			return
+		}
+		hashes = append(hashes, h)
+	}
+
+	// a hash only matches through an identifier its owner made discoverable
+	rows, err := db.Query(r.Context(),
+		`SELECT DISTINCT ON (u.id) h.hash, u.id, COALESCE(u.name, ''), u.handle, u.picture,
+			f.status, f.requester_id::text
+		 FROM user_contact_hashes h
+		 JOIN users u ON u.id = h.user_id
+		 LEFT JOIN friendships f
+			ON f.user_a_id = LEAST(u.id, $1::uuid)
+			AND f.user_b_id = GREATEST(u.id, $1::uuid)
+		 WHERE h.hash = ANY($2)
+		 AND ((h.kind = 'email' AND u.discoverable_by_email) OR (h.kind = 'phone' AND u.discoverable_by_phone))
+		 AND u.id <> $1::uuid
+		 AND (f.status IS NULL OR f.status <> 'blocked')
+		 ORDER BY u.id, h.kind`,
+		userID, hashes,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	matches := make([]ContactMatch, 0)
+	for rows.Next() {
+		var m ContactMatch
+		var status, requesterID *string
+		if err := rows.Scan(&m.Hash, &m.ID, &m.Name, &m.Handle, &m.Picture, &status, &requesterID); err != nil {
+			continue
+		}
+		m.Relationship = relationshipFor(userID, status, requesterID)
+		matches = append(matches, m)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"matches": matches,
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+)
+
+type DeviceTokenInput struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"` // ios or android
+}
+
+func handleDevices(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		handleRegisterDevice(w, r)
+	case http.MethodDelete:
+		handleUnregisterDevice(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func decodeDeviceToken(w http.ResponseWriter, r *http.Request) (DeviceTokenInput, bool) {
+	var req DeviceTokenInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return req, false
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if !strings.HasPrefix(req.Token, "ExponentPushToken[") && !strings.HasPrefix(req.Token, "ExpoPushToken[") {
+		http.Error(w, "token must be an Expo push token", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+func handleRegisterDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	req, ok := decodeDeviceToken(w, r)
+	if !ok {
+		return
+	}
+	if req.Platform != "" && req.Platform != "ios" && req.Platform != "android" {
+		http.Error(w, "platform must be ios or android", http.StatusBadRequest)
+		return
+	}
+
+	// a device that signs into another account moves over to it
+	_, err := db.Exec(r.Context(),
+		`INSERT INTO device_tokens (token, user_id, platform) VALUES ($1, $2, NULLIF($3, ''))
+		 ON CONFLICT (token) DO UPDATE
+		 SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform, last_seen_at = NOW()`,
+		req.Token, userID, req.Platform,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "device_registered",
+	})
+}
+
+func handleUnregisterDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	req, ok := decodeDeviceToken(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec(r.Context(),
+		"DELETE FROM device_tokens WHERE token = $1 AND user_id = $2",
+		req.Token, userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "device_unregistered",
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+)
+
+var unsubscribeConfirmTemplate = htmltemplate.Must(htmltemplate.New("unsubscribe").Parse(
+	`<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; text-align: center; padding: 48px;">
+<p>Stop getting the SNAPSHOT daily digest?</p>
+<form method="post" action="/api/email/unsubscribe?token={{.}}">
+<button type="submit" style="font-size: 16px; padding: 8px 24px;">Unsubscribe</button>
+</form>
+</body>
+</html>
+`))
+
+// handleUnsubscribeDigest turns the daily digest off for whoever the signed token was
+// issued to. Only a POST unsubscribes: mail clients send one for one-click
+// unsubscribe (RFC 8058), and opening the link from the email is a GET that shows a
+// form to confirm, so link scanners and prefetchers can't unsubscribe anyone.
+// Neither needs a session.
+func handleUnsubscribeDigest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	userID, ok := parseUnsubscribeToken(token)
+	if !ok {
+		http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		unsubscribeConfirmTemplate.Execute(w, token)
+		return
+	}
+
+	_, err := db.Exec(r.Context(),
+		`INSERT INTO notification_preferences (user_id, daily_digest) VALUES ($1, FALSE)
+		 ON CONFLICT (user_id) DO UPDATE SET daily_digest = FALSE, updated_at = NOW()`,
+		userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; text-align: center; padding: 48px;">
+<p>You're unsubscribed from the SNAPSHOT daily digest.</p>
+<p style="color: #666;">You can turn it back on from notification settings in the app.</p>
+</body>
+</html>
+`)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+)
+
+const (
+	feedEventBatchSize = 100
+	// streams send a comment this often so proxies keep the connection open, and
+	// read the table at the same time in case a wake-up was missed
+	feedStreamHeartbeat = 25 * time.Second
+)
+
+// handleEventStream streams group activity as Server-Sent Events. Like every other
+// endpoint it authenticates with the Authorization header, so clients use a fetch
+// based event source rather than putting the token in the URL. Each event's id can
+// be sent back as Last-Event-ID to resume; without it the stream starts from now.
+// A reset event means the stream couldn't be resumed, because the ID was too old or
+// unknown, and the client should refetch.
+func handleEventStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+	var lastID int64
+	reset := false
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		resumable, err := canResumeFeed(ctx, id)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		lastID, reset = id, !resumable
+	}
+	if lastID == 0 || reset {
+		latest, err := latestFeedEventID(ctx)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		lastID = latest
+	}
+
+	// subscribe before the first read so nothing recorded in between is missed
+	wake, unsubscribe := feedHub.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if reset {
+		fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {}\n\n", lastID)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(feedStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		events, readTo, waiting, err := loadFeedEvents(ctx, userID, lastID, feedEventBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Event stream error: %v", err)
+			}
+			return
+		}
+		advanced := readTo > lastID
+		lastID = readTo
+
+		for _, e := range events {
+			payload, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, payload)
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+		}
+
+		// keep reading until caught up, a batch may not have held everything
+		if advanced && !waiting {
+			continue
+		}
+
+		var retry <-chan time.Time
+		if waiting {
+			retry = time.After(feedEventGapWait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-retry:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
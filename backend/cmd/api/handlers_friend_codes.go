@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/ratelimit"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	defaultQRSize = 256
+	maxQRSize     = 1024
+)
+
+var errFriendCodeInvalid = errors.New("friend code is invalid or no longer usable")
+
+// redeemLimiter caps redemptions per user so friend links can't be guessed.
+var redeemLimiter = ratelimit.New(20, time.Hour)
+
+type FriendCode struct {
+	Code      string    `json:"code"`
+	SingleUse bool      `json:"single_use"`
+	Link      string    `json:"link"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// friendCodeSecret signs deep links. It falls back to JWT_SECRET so links work
+// without extra configuration.
+func friendCodeSecret() []byte {
+	if secret := os.Getenv("FRIEND_CODE_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+func signFriendCode(code string) string {
+	mac := hmac.New(sha256.New, friendCodeSecret())
+	mac.Write([]byte("friend-code:" + code))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// friendCodeLink returns the signed deep link, e.g. snapshot://friend/ABCD2345?sig=...
+func friendCodeLink(code string) string {
+	base := os.Getenv("FRIEND_LINK_BASE")
+	if base == "" {
+		base = "snapshot://friend"
+	}
+	return strings.TrimSuffix(base, "/") + "/" + code + "?sig=" + signFriendCode(code)
+}
+
+// parseFriendCodeLink extracts the code from a deep link and checks its signature.
+func parseFriendCodeLink(link string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return "", errFriendCodeInvalid
+	}
+	parts := strings.Split(strings.Trim(u.Opaque+u.Host+u.Path, "/"), "/")
+	code := strings.ToUpper(parts[len(parts)-1])
+
+	expected := signFriendCode(code)
+	if !hmac.Equal([]byte(expected), []byte(u.Query().Get("sig"))) {
+		return "", errFriendCodeInvalid
+	}
+	return code, nil
+}
+
+func toFriendCode(code string, singleUse bool, createdAt time.Time) FriendCode {
+	return FriendCode{
+		Code:      code,
+		SingleUse: singleUse,
+		Link:      friendCodeLink(code),
+		CreatedAt: createdAt,
+	}
+}
+
+func createFriendCode(ctx context.Context, q dbtx, userID string, singleUse bool) (FriendCode, error) {
+	code, err := generateInviteCode()
+	if err != nil {
+		return FriendCode{}, err
+	}
+	codeID, _ := uuid.NewV7()
+
+	var createdAt time.Time
+	err = q.QueryRow(ctx,
+		`INSERT INTO friend_codes (id, user_id, code, single_use)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING created_at`,
+		codeID, userID, code, singleUse,
+	).Scan(&createdAt)
+	if err != nil {
+		return FriendCode{}, err
+	}
+	return toFriendCode(code, singleUse, createdAt), nil
+}
+
+// getOrCreateFriendCode returns the user's usable code, creating a reusable one if
+// they have none.
+func getOrCreateFriendCode(ctx context.Context, userID string) (FriendCode, error) {
+	fc, err := getOrCreateFriendCodeOnce(ctx, userID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		// a concurrent request created it first, or the random code collided
+		return getOrCreateFriendCodeOnce(ctx, userID)
+	}
+	return fc, err
+}
+
+func getOrCreateFriendCodeOnce(ctx context.Context, userID string) (FriendCode, error) {
+	var code string
+	var singleUse bool
+	var createdAt time.Time
+	err := db.QueryRow(ctx,
+		`SELECT code, single_use, created_at FROM friend_codes
+		 WHERE user_id = $1 AND revoked_at IS NULL AND used_at IS NULL`,
+		userID,
+	).Scan(&code, &singleUse, &createdAt)
+	if err == nil {
+		return toFriendCode(code, singleUse, createdAt), nil
+	}
+	if err != pgx.ErrNoRows {
+		return FriendCode{}, err
+	}
+
+	return createFriendCode(ctx, db, userID, false)
+}
+
+func revokeFriendCode(ctx context.Context, q dbtx, userID string) (int64, error) {
+	tag, err := q.Exec(ctx,
+		`UPDATE friend_codes SET revoked_at = NOW()
+		 WHERE user_id = $1 AND revoked_at IS NULL AND used_at IS NULL`,
+		userID,
+	)
+	return tag.RowsAffected(), err
+}
+
+func handleFriendCode(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleGetFriendCode(w, r)
+	case http.MethodDelete:
+		handleRevokeFriendCode(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleGetFriendCode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	fc, err := getOrCreateFriendCode(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]FriendCode{
+		"friend_code": fc,
+	})
+}
+
+func handleRevokeFriendCode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	revoked, err := revokeFriendCode(r.Context(), db, userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if revoked == 0 {
+		http.Error(w, "No active friend code", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "friend_code_revoked",
+	})
+}
+
+type RotateFriendCodeRequest struct {
+	SingleUse bool `json:"single_use"`
+}
+
+func handleRotateFriendCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req RotateFriendCodeRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	if _, err := revokeFriendCode(r.Context(), tx, userID); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	fc, err := createFriendCode(r.Context(), tx, userID, req.SingleUse)
+	if err != nil {
+		http.Error(w, "Failed to create friend code", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]FriendCode{
+		"friend_code": fc,
+	})
+}
+
+func handleGetFriendCodeQR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	size := defaultQRSize
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 64 || n > maxQRSize {
+			http.Error(w, "size must be between 64 and 1024", http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+
+	fc, err := getOrCreateFriendCode(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	png, err := qrcode.Encode(fc.Link, qrcode.Medium, size)
+	if err != nil {
+		http.Error(w, "Failed to render QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
+
+// RedeemFriendCodeRequest takes either a scanned link, whose signature is checked,
+// or a code typed in by hand.
+// RedeemFriendCodeRequest takes the signed link rather than the bare code, so only
+// someone who was shown the link or QR code can use it.
+type RedeemFriendCodeRequest struct {
+	Link string `json:"link"`
+}
+
+func handleRedeemFriendCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if allowed, retryAfter := redeemLimiter.Allow(userID); !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "Too many attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	var req RedeemFriendCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Link) == "" {
+		http.Error(w, "link is required", http.StatusBadRequest)
+		return
+	}
+
+	code, err := parseFriendCodeLink(req.Link)
+	if err != nil {
+		http.Error(w, "Invalid friend link", http.StatusBadRequest)
+		return
+	}
+
+	var codeID, ownerID string
+	var singleUse bool
+	err = db.QueryRow(r.Context(),
+		`SELECT id, user_id, single_use FROM friend_codes
+		 WHERE code = $1 AND revoked_at IS NULL AND used_at IS NULL`,
+		code,
+	).Scan(&codeID, &ownerID, &singleUse)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Friend code not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if ownerID == userID {
+		http.Error(w, "You cannot friend yourself", http.StatusBadRequest)
+		return
+	}
+
+	if singleUse {
+		// claim the code first so two scans can't both use it
+		tag, err := db.Exec(r.Context(),
+			"UPDATE friend_codes SET used_at = NOW() WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL",
+			codeID,
+		)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			http.Error(w, "Friend code not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	status, err := sendFriendRequest(r.Context(), userID, ownerID, "")
+	if err != nil && singleUse {
+		// nothing came of the scan, so the code stays usable
+		if _, releaseErr := db.Exec(r.Context(), "UPDATE friend_codes SET used_at = NULL WHERE id = $1", codeID); releaseErr != nil {
+			log.Printf("Failed to release friend code %s: %v", codeID, releaseErr)
+		}
+	}
+	if !writeFriendRequestError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status == "request_sent" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":    status,
+		"target_id": ownerID,
+	})
+}
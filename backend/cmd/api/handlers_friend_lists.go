@@ -0,0 +1,616 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	maxFriendListNameLength = 50
+
+	FriendListCustom       = "custom"
+	FriendListCloseFriends = "close_friends"
+	closeFriendsListName   = "Close Friends"
+)
+
+type FriendList struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"` // custom, or close_friends for the built-in list
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// removeFromFriendLists takes each user off the other's lists, close friends
+// included. Lists only hold accepted friends, so this runs whenever a friendship ends.
+func removeFromFriendLists(ctx context.Context, q dbtx, userID, otherID string) error {
+	_, err := q.Exec(ctx,
+		`DELETE FROM friend_list_members flm
+		 USING friend_lists fl
+		 WHERE fl.id = flm.list_id
+		 AND ((fl.user_id = $1 AND flm.friend_id = $2) OR (fl.user_id = $2 AND flm.friend_id = $1))`,
+		userID, otherID,
+	)
+	return err
+}
+
+// friendListMemberIDs returns the members of the given lists, all of which must
+// belong to userID. Unknown or foreign lists are reported as errFriendListNotFound.
+func friendListMemberIDs(ctx context.Context, q dbtx, userID string, listIDs []string) ([]string, error) {
+	listIDs = uniqueIDs(listIDs)
+	if len(listIDs) == 0 {
+		return nil, nil
+	}
+
+	var owned int
+	err := q.QueryRow(ctx,
+		"SELECT COUNT(*) FROM friend_lists WHERE user_id = $1 AND id::text = ANY($2)",
+		userID, listIDs,
+	).Scan(&owned)
+	if err != nil {
+		return nil, err
+	}
+	if owned != len(listIDs) {
+		return nil, errFriendListNotFound
+	}
+
+	rows, err := q.Query(ctx,
+		`SELECT DISTINCT friend_id::text FROM friend_list_members
+		 WHERE list_id::text = ANY($1)`,
+		listIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberIDs := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		memberIDs = append(memberIDs, id)
+	}
+	return memberIDs, rows.Err()
+}
+
+var errFriendListNotFound = errors.New("friend list not found")
+
+// ensureCloseFriendsList returns the user's built-in close friends list, creating it
+// if it's missing. A custom list already named Close Friends becomes the built-in one.
+// Users get the list when they sign up, so only write paths need to call this.
+func ensureCloseFriendsList(ctx context.Context, q dbtx, userID string) (string, error) {
+	listID, _ := uuid.NewV7()
+	_, err := q.Exec(ctx,
+		`INSERT INTO friend_lists (id, user_id, name, kind) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT DO NOTHING`,
+		listID, userID, closeFriendsListName, FriendListCloseFriends,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+	err = q.QueryRow(ctx,
+		"SELECT id::text FROM friend_lists WHERE user_id = $1 AND kind = $2",
+		userID, FriendListCloseFriends,
+	).Scan(&id)
+	if err != pgx.ErrNoRows {
+		return id, err
+	}
+
+	err = q.QueryRow(ctx,
+		`UPDATE friend_lists SET kind = $3
+		 WHERE user_id = $1 AND lower(name) = lower($2)
+		 RETURNING id::text`,
+		userID, closeFriendsListName, FriendListCloseFriends,
+	).Scan(&id)
+	return id, err
+}
+
+// requireFriendList checks the list in the path belongs to the caller.
+func requireFriendList(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", "", false
+	}
+
+	listID := r.PathValue("id")
+	var owns bool
+	err := db.QueryRow(r.Context(),
+		"SELECT EXISTS(SELECT 1 FROM friend_lists WHERE id::text = $1 AND user_id = $2)",
+		listID, userID,
+	).Scan(&owns)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return "", "", false
+	}
+	if !owns {
+		http.Error(w, "Friend list not found", http.StatusNotFound)
+		return "", "", false
+	}
+	return userID, listID, true
+}
+
+func normalizeFriendListName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	return name, name != "" && len([]rune(name)) <= maxFriendListNameLength
+}
+
+func handleFriendLists(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleListFriendLists(w, r)
+	case http.MethodPost:
+		handleCreateFriendList(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleListFriendLists(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := db.Query(r.Context(),
+		`SELECT fl.id, fl.name, fl.kind,
+			(SELECT COUNT(*) FROM friend_list_members flm WHERE flm.list_id = fl.id),
+			fl.created_at
+		 FROM friend_lists fl
+		 WHERE fl.user_id = $1
+		 ORDER BY fl.kind = 'custom', lower(fl.name) ASC`,
+		userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	lists := make([]FriendList, 0)
+	for rows.Next() {
+		var fl FriendList
+		if err := rows.Scan(&fl.ID, &fl.Name, &fl.Kind, &fl.MemberCount, &fl.CreatedAt); err != nil {
+			continue
+		}
+		lists = append(lists, fl)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"lists": lists,
+	})
+}
+
+type FriendListInput struct {
+	Name string `json:"name"`
+}
+
+func handleCreateFriendList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req FriendListInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	name, valid := normalizeFriendListName(req.Name)
+	if !valid {
+		http.Error(w, "List name must be 1-50 characters", http.StatusBadRequest)
+		return
+	}
+
+	// so a custom list can't take the built-in list's name
+	if _, err := ensureCloseFriendsList(r.Context(), db, userID); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	listID, _ := uuid.NewV7()
+	fl := FriendList{ID: listID.String(), Name: name, Kind: FriendListCustom}
+	err := db.QueryRow(r.Context(),
+		`INSERT INTO friend_lists (id, user_id, name) VALUES ($1, $2, $3)
+		 RETURNING created_at`,
+		listID, userID, name,
+	).Scan(&fl.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			http.Error(w, "You already have a list with this name", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]FriendList{
+		"list": fl,
+	})
+}
+
+func handleFriendList(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleGetFriendList(w, r)
+	case http.MethodPatch:
+		handleRenameFriendList(w, r)
+	case http.MethodDelete:
+		handleDeleteFriendList(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleGetFriendList(w http.ResponseWriter, r *http.Request) {
+	_, listID, ok := requireFriendList(w, r)
+	if !ok {
+		return
+	}
+
+	var fl FriendList
+	err := db.QueryRow(r.Context(),
+		"SELECT id, name, kind, created_at FROM friend_lists WHERE id = $1",
+		listID,
+	).Scan(&fl.ID, &fl.Name, &fl.Kind, &fl.CreatedAt)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := db.Query(r.Context(),
+		`SELECT u.id, COALESCE(u.name, ''), COALESCE(u.picture, '')
+		 FROM friend_list_members flm
+		 JOIN users u ON u.id = flm.friend_id
+		 WHERE flm.list_id = $1
+		 ORDER BY lower(u.name) ASC`,
+		listID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	members := make([]Friend, 0)
+	for rows.Next() {
+		var f Friend
+		if err := rows.Scan(&f.ID, &f.Name, &f.Picture); err != nil {
+			continue
+		}
+		members = append(members, f)
+	}
+	fl.MemberCount = len(members)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"list":    fl,
+		"members": members,
+	})
+}
+
+func handleRenameFriendList(w http.ResponseWriter, r *http.Request) {
+	userID, listID, ok := requireFriendList(w, r)
+	if !ok {
+		return
+	}
+
+	var req FriendListInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	name, valid := normalizeFriendListName(req.Name)
+	if !valid {
+		http.Error(w, "List name must be 1-50 characters", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := ensureCloseFriendsList(r.Context(), db, userID); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := db.Exec(r.Context(),
+		"UPDATE friend_lists SET name = $1 WHERE id = $2 AND kind = 'custom'",
+		name, listID,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			http.Error(w, "You already have a list with this name", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "The close friends list can't be renamed", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "list_renamed",
+		"id":     listID,
+		"name":   name,
+	})
+}
+
+func handleDeleteFriendList(w http.ResponseWriter, r *http.Request) {
+	_, listID, ok := requireFriendList(w, r)
+	if !ok {
+		return
+	}
+
+	// photos shared only with this list fall back to nobody but their owner
+	result, err := db.Exec(r.Context(), "DELETE FROM friend_lists WHERE id = $1 AND kind = 'custom'", listID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "The close friends list can't be deleted", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "list_deleted",
+	})
+}
+
+type FriendListMemberInput struct {
+	TargetID string `json:"target_id"`
+}
+
+func handleAddFriendListMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, listID, ok := requireFriendList(w, r)
+	if !ok {
+		return
+	}
+
+	var req FriendListMemberInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	req.TargetID = strings.TrimSpace(req.TargetID)
+	if req.TargetID == "" {
+		http.Error(w, "target_id is required", http.StatusBadRequest)
+		return
+	}
+
+	status, _, err := getFriendshipStatus(r.Context(), db, userID, req.TargetID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if status != "accepted" {
+		http.Error(w, "Friendship not found", http.StatusNotFound)
+		return
+	}
+
+	_, err = db.Exec(r.Context(),
+		`INSERT INTO friend_list_members (list_id, friend_id) VALUES ($1, $2)
+		 ON CONFLICT DO NOTHING`,
+		listID, req.TargetID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":    "list_member_added",
+		"list_id":   listID,
+		"target_id": req.TargetID,
+	})
+}
+
+func handleRemoveFriendListMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, listID, ok := requireFriendList(w, r)
+	if !ok {
+		return
+	}
+
+	targetID := r.PathValue("userId")
+	result, err := db.Exec(r.Context(),
+		"DELETE FROM friend_list_members WHERE list_id = $1 AND friend_id::text = $2",
+		listID, targetID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Not on this list", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":    "list_member_removed",
+		"list_id":   listID,
+		"target_id": targetID,
+	})
+}
+
+// handleCloseFriends keeps the close friends API from before friend lists working. It
+// reads and edits the built-in close friends list.
+func handleCloseFriends(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleListCloseFriends(w, r)
+	case http.MethodPost:
+		handleAddCloseFriend(w, r)
+	case http.MethodDelete:
+		handleRemoveCloseFriend(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleListCloseFriends(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := db.Query(r.Context(),
+		`SELECT u.id, u.name, u.picture
+		 FROM friend_lists fl
+		 JOIN friend_list_members flm ON flm.list_id = fl.id
+		 JOIN users u ON u.id = flm.friend_id
+		 WHERE fl.user_id = $1 AND fl.kind = $2
+		 ORDER BY lower(u.name) ASC`,
+		userID, FriendListCloseFriends,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	friends := make([]Friend, 0)
+	for rows.Next() {
+		var f Friend
+		var pic *string
+		if err := rows.Scan(&f.ID, &f.Name, &pic); err != nil {
+			continue
+		}
+		if pic != nil {
+			f.Picture = *pic
+		}
+		friends = append(friends, f)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"close_friends": friends,
+	})
+}
+
+func handleAddCloseFriend(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req FriendListMemberInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	req.TargetID = strings.TrimSpace(req.TargetID)
+	if req.TargetID == "" {
+		http.Error(w, "target_id is required", http.StatusBadRequest)
+		return
+	}
+
+	status, _, err := getFriendshipStatus(r.Context(), db, userID, req.TargetID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if status != "accepted" {
+		http.Error(w, "Friendship not found", http.StatusNotFound)
+		return
+	}
+
+	listID, err := ensureCloseFriendsList(r.Context(), db, userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = db.Exec(r.Context(),
+		`INSERT INTO friend_list_members (list_id, friend_id) VALUES ($1, $2)
+		 ON CONFLICT DO NOTHING`,
+		listID, req.TargetID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "close_friend_added",
+	})
+}
+
+func handleRemoveCloseFriend(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req FriendListMemberInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if req.TargetID == "" {
+		http.Error(w, "target_id is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.Exec(r.Context(),
+		`DELETE FROM friend_list_members flm
+		 USING friend_lists fl
+		 WHERE fl.id = flm.list_id AND fl.user_id = $1 AND fl.kind = $2
+		 AND flm.friend_id::text = $3`,
+		userID, FriendListCloseFriends, req.TargetID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Close friend not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "close_friend_removed",
+	})
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
 	"github.com/jackc/pgx/v5"
@@ -14,6 +16,7 @@ import (
 
 type FriendRequestInput struct {
 	TargetEmail string `json:"target_email"`
+	Message     string `json:"message"`
 }
 
 func handleFriendRequest(w http.ResponseWriter, r *http.Request) {
@@ -38,7 +41,11 @@ func handleFriendRequest(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "target_email is required", http.StatusBadRequest)
 		return
 	}
-	fmt.Print(req.TargetEmail)
+	req.Message = strings.TrimSpace(req.Message)
+	if utf8.RuneCountInString(req.Message) > maxFriendRequestMessageLength {
+		http.Error(w, "Message too long (max 140 characters)", http.StatusBadRequest)
+		return
+	}
 
 	var targetID string
 	err := db.QueryRow(r.Context(), "SELECT id FROM users WHERE email = $1", req.TargetEmail).Scan(&targetID)
@@ -55,31 +62,141 @@ func handleFriendRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	status, err := sendFriendRequest(r.Context(), requesterID, targetID, req.Message)
+	if !writeFriendRequestError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status == "request_sent" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":    status,
+		"target_id": targetID,
+	})
+}
+
+var (
+	errFriendTargetNotFound  = errors.New("user not found")
+	errFriendshipExists      = errors.New("friendship status already exists (pending or accepted)")
+	errFriendRequestCooldown = errors.New("this user recently declined a request from you")
+)
+
+// sendFriendRequest creates a pending request from requesterID to targetID. If the
+// target already asked the requester, the request is accepted instead. It returns
+// "request_sent" or "friendship_accepted". An empty message is stored as NULL.
+func sendFriendRequest(ctx context.Context, requesterID, targetID, message string) (string, error) {
 	userA, userB := requesterID, targetID
 	if requesterID > targetID {
 		userA, userB = targetID, requesterID
 	}
 
-	_, err = db.Exec(r.Context(),
-		`INSERT INTO friendships (user_a_id, user_b_id, status, requester_id)
-		 VALUES ($1, $2, 'pending', $3)`,
-		userA, userB, requesterID,
-	)
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	status, existingRequester, err := getFriendshipStatus(ctx, tx, requesterID, targetID)
+	if err != nil {
+		return "", err
+	}
+
+	result := "request_sent"
+	switch {
+	case status == "blocked":
+		// a block in either direction looks the same as an unknown user
+		return "", errFriendTargetNotFound
+	case status == "pending" && existingRequester == targetID:
+		_, err = tx.Exec(ctx,
+			`UPDATE friendships
+			 SET status = 'accepted', requester_id = $1, message = NULL
+			 WHERE user_a_id = $2 AND user_b_id = $3
+			 AND status = 'pending'`,
+			requesterID, userA, userB,
+		)
+		result = "friendship_accepted"
+	case status != "":
+		return "", errFriendshipExists
+	default:
+		var coolingDown bool
+		err = tx.QueryRow(ctx,
+			`SELECT EXISTS(
+				SELECT 1 FROM friend_request_rejections
+				WHERE rejector_id = $1 AND requester_id = $2 AND rejected_at > $3
+			)`,
+			targetID, requesterID, time.Now().Add(-friendRequestCooldown()),
+		).Scan(&coolingDown)
+		if err != nil {
+			return "", err
+		}
+		if coolingDown {
+			return "", errFriendRequestCooldown
+		}
+
+		_, err = tx.Exec(ctx,
+			`INSERT INTO friendships (user_a_id, user_b_id, status, requester_id, message, created_at)
+			 VALUES ($1, $2, 'pending', $3, NULLIF($4, ''), NOW())`,
+			userA, userB, requesterID, message,
+		)
+	}
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			http.Error(w, "Friendship status already exists (pending or accepted)", http.StatusConflict)
-			return
+			return "", errFriendshipExists
 		}
+		return "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+
+	if result == "friendship_accepted" {
+		notifyFriendAccepted(ctx, requesterID, targetID)
+	} else {
+		notifyFriendRequest(ctx, requesterID, targetID)
+	}
+	return result, nil
+}
+
+// writeFriendRequestError maps sendFriendRequest errors to responses and reports
+// whether the request succeeded.
+func writeFriendRequestError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errFriendTargetNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, errFriendshipExists):
+		http.Error(w, "Friendship status already exists (pending or accepted)", http.StatusConflict)
+	case errors.Is(err, errFriendRequestCooldown):
+		http.Error(w, "You can send this user another request later", http.StatusTooManyRequests)
+	default:
 		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
 	}
+	return false
+}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":    "request_sent",
-		"target_id": targetID,
-	})
+// getFriendshipStatus returns the pair's friendship status and who last acted on it,
+// or empty strings when the two users have no relationship.
+func getFriendshipStatus(ctx context.Context, q dbtx, userID, otherID string) (string, string, error) {
+	userA, userB := userID, otherID
+	if userID > otherID {
+		userA, userB = otherID, userID
+	}
+
+	var status, requesterID string
+	err := q.QueryRow(ctx,
+		`SELECT status, requester_id FROM friendships
+		 WHERE user_a_id::text = $1 AND user_b_id::text = $2`,
+		userA, userB,
+	).Scan(&status, &requesterID)
+	if err == pgx.ErrNoRows {
+		return "", "", nil
+	}
+	return status, requesterID, err
 }
 
 type FriendAcceptInput struct {
@@ -114,7 +231,7 @@ func handleAcceptFriend(w http.ResponseWriter, r *http.Request) {
 
 	commandTag, err := db.Exec(r.Context(),
 		`UPDATE friendships
-		 SET status = 'accepted', requester_id = $1
+		 SET status = 'accepted', requester_id = $1, message = NULL
 		 WHERE user_a_id = $2 AND user_b_id = $3
 		 AND status = 'pending'
 		 AND requester_id != $1`,
@@ -130,6 +247,8 @@ func handleAcceptFriend(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	notifyFriendAccepted(r.Context(), acceptorID, req.TargetID)
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
 		"status": "friendship_accepted",
@@ -220,10 +339,18 @@ func handleRejectFriend(w http.ResponseWriter, r *http.Request) {
 		userA, userB = req.TargetID, rejectorID
 	}
 
+	// remember the rejection so the requester has to wait before asking again
 	commandTag, err := db.Exec(r.Context(),
-		`DELETE FROM friendships
-		 WHERE user_a_id = $1 AND user_b_id = $2
-		 AND requester_id != $3`,
+		`WITH rejected AS (
+			DELETE FROM friendships
+			WHERE user_a_id = $1 AND user_b_id = $2
+			AND status = 'pending'
+			AND requester_id != $3
+			RETURNING requester_id
+		)
+		INSERT INTO friend_request_rejections (rejector_id, requester_id, rejected_at)
+		SELECT $3, requester_id, NOW() FROM rejected
+		ON CONFLICT (rejector_id, requester_id) DO UPDATE SET rejected_at = EXCLUDED.rejected_at`,
 		userA, userB, rejectorID,
 	)
 	if err != nil {
@@ -300,10 +427,28 @@ func handleCancelFriendRequest(w http.ResponseWriter, r *http.Request) {
 }
 
 type PendingRequest struct {
-	ID      string `json:"id"`
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Picture string `json:"picture"`
+	ID          string    `json:"id"`
+	Email       string    `json:"email"`
+	Name        string    `json:"name"`
+	Picture     string    `json:"picture"`
+	Message     *string   `json:"message"`
+	MutualCount int       `json:"mutual_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func scanPendingRequests(rows pgx.Rows) []PendingRequest {
+	expiry := friendRequestExpiry()
+	requests := make([]PendingRequest, 0)
+	for rows.Next() {
+		var pr PendingRequest
+		if err := rows.Scan(&pr.ID, &pr.Email, &pr.Name, &pr.Picture, &pr.Message, &pr.MutualCount, &pr.CreatedAt); err != nil {
+			continue
+		}
+		pr.ExpiresAt = pr.CreatedAt.Add(expiry)
+		requests = append(requests, pr)
+	}
+	return requests
 }
 
 func handleListIncomingFriendRequests(w http.ResponseWriter, r *http.Request) {
@@ -319,7 +464,7 @@ func handleListIncomingFriendRequests(w http.ResponseWriter, r *http.Request) {
 	}
 
 	query := `
-		SELECT u.id, u.email, u.name, u.picture
+		SELECT u.id, u.email, u.name, u.picture, f.message, ` + mutualFriendCountSQL("$1::uuid", "u.id") + `, f.created_at
 		FROM friendships f
 		JOIN users u ON u.id = f.requester_id
 		WHERE f.status = 'pending'
@@ -335,14 +480,7 @@ func handleListIncomingFriendRequests(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	requests := make([]PendingRequest, 0)
-	for rows.Next() {
-		var pr PendingRequest
-		if err := rows.Scan(&pr.ID, &pr.Email, &pr.Name, &pr.Picture); err != nil {
-			continue
-		}
-		requests = append(requests, pr)
-	}
+	requests := scanPendingRequests(rows)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -363,7 +501,7 @@ func handleListOutgoingFriendRequests(w http.ResponseWriter, r *http.Request) {
 	}
 
 	query := `
-		SELECT u.id, u.email, u.name, u.picture
+		SELECT u.id, u.email, u.name, u.picture, f.message, ` + mutualFriendCountSQL("$1::uuid", "u.id") + `, f.created_at
 		FROM friendships f
 		JOIN users u ON u.id = CASE
 			WHEN f.user_a_id = $1 THEN f.user_b_id
@@ -382,14 +520,7 @@ func handleListOutgoingFriendRequests(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	requests := make([]PendingRequest, 0)
-	for rows.Next() {
-		var pr PendingRequest
-		if err := rows.Scan(&pr.ID, &pr.Email, &pr.Name, &pr.Picture); err != nil {
-			continue
-		}
-		requests = append(requests, pr)
-	}
+	requests := scanPendingRequests(rows)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -427,7 +558,14 @@ func handleRemoveFriend(w http.ResponseWriter, r *http.Request) {
 		userA, userB = req.TargetID, userID
 	}
 
-	result, err := db.Exec(r.Context(),
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	result, err := tx.Exec(r.Context(),
 		`DELETE FROM friendships
 		 WHERE user_a_id = $1 AND user_b_id = $2
 		 AND status = 'accepted'`,
@@ -443,6 +581,17 @@ func handleRemoveFriend(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// lists only hold friends, so drop the pair from both users' lists
+	if err := removeFromFriendLists(r.Context(), tx, userA, userB); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
 		"status": "friend_removed",
@@ -1,20 +1,29 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/webhooks"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type CreateGroupRequest struct {
-	Name    string   `json:"name"`
-	Members []string `json:"members"`
+	Name             string   `json:"name"`
+	Members          []string `json:"members"`
+	ListIDs          []string `json:"list_ids"` // friend lists whose members are added as a preset
+	Description      string   `json:"description"`
+	MemberLimit      *int     `json:"member_limit"`
+	ShowRetakes      bool     `json:"show_retakes"`
+	RequiresApproval bool     `json:"requires_approval"`
 }
 
 type Group struct {
@@ -101,6 +110,19 @@ func createGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var description *string
+	if d := strings.TrimSpace(req.Description); d != "" {
+		if utf8.RuneCountInString(d) > maxGroupDescriptionLength {
+			http.Error(w, "Description too long (max 500 characters)", http.StatusBadRequest)
+			return
+		}
+		description = &d
+	}
+
+	if req.MemberLimit != nil && *req.MemberLimit <= 0 {
+		req.MemberLimit = nil
+	}
+
 	groupID, _ := uuid.NewV7()
 
 	// start a transaction (all or nothing)
@@ -114,8 +136,9 @@ func createGroup(w http.ResponseWriter, r *http.Request) {
 	// insert group into groups
 	_, err = tx.Exec(
 		r.Context(),
-		"INSERT INTO groups (id, owner_id, name) VALUES ($1, $2, $3)",
-		groupID, userID, req.Name,
+		`INSERT INTO groups (id, owner_id, name, description, member_limit, show_retakes, requires_approval)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		groupID, userID, req.Name, description, req.MemberLimit, req.ShowRetakes, req.RequiresApproval,
 	)
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -130,7 +153,7 @@ func createGroup(w http.ResponseWriter, r *http.Request) {
 	// automatically add user into group
 	_, err = tx.Exec(
 		r.Context(),
-		"INSERT INTO group_members (group_id, user_id) VALUES ($1, $2)",
+		"INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'owner')",
 		groupID, userID,
 	)
 	if err != nil {
@@ -138,21 +161,20 @@ func createGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for _, memberID := range req.Members {
-		if memberID == userID {
-			continue
-		}
+	presetMembers, err := friendListMemberIDs(r.Context(), tx, userID, req.ListIDs)
+	if errors.Is(err, errFriendListNotFound) {
+		http.Error(w, "Friend list not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
 
-		_, err := tx.Exec(r.Context(),
-			`INSERT INTO group_members (group_id, user_id) VALUES ($1, $2)
-			 ON CONFLICT DO NOTHING`,
-			groupID, memberID,
-		)
-		if err != nil {
-			log.Printf("Failed to add member %s: %v", memberID, err)
-			http.Error(w, "Failed to add members", http.StatusInternalServerError)
-			return
-		}
+	memberResults, err := addInitialMembers(r.Context(), tx, groupID.String(), userID, append(req.Members, presetMembers...), req.MemberLimit)
+	if err != nil {
+		log.Printf("Failed to add members: %v", err)
+		http.Error(w, "Failed to add members", http.StatusInternalServerError)
+		return
 	}
 
 	// handle all errors
@@ -161,72 +183,144 @@ func createGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	added, invited := make([]string, 0), make([]string, 0)
+	for _, m := range memberResults {
+		switch m.Result {
+		case MemberAdded:
+			added = append(added, m.UserID)
+		case MemberInvited:
+			invited = append(invited, m.UserID)
+		}
+	}
+	notifyGroupAdded(r.Context(), userID, groupID.String(), added)
+	notifyGroupInvite(r.Context(), userID, groupID.String(), invited)
+
 	// return success
-	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]Group{
-		"group": {
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"group": Group{
 			ID:      groupID.String(),
 			OwnerID: userID,
 			Name:    req.Name,
 		},
+		"members": memberResults,
 	})
 }
 
-type JoinGroupRequest struct {
-	GroupID string `json:"group_id"`
+type GroupMember struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Picture string `json:"picture"`
+	Role    string `json:"role"`
 }
 
-func handleJoinGroup(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+const (
+	MemberAdded    = "added"
+	MemberInvited  = "invited"
+	MemberRejected = "rejected"
+)
 
-	userID, ok := auth.GetUserID(r)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
+type MemberAddResult struct {
+	UserID string `json:"user_id"`
+	Result string `json:"result"` // added, invited or rejected
+	Reason string `json:"reason,omitempty"`
+}
 
-	var req JoinGroupRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "hella Invalid request body", http.StatusBadRequest)
-		return
+// addInitialMembers adds the creator's accepted friends straight into a new group and
+// sends everyone else a direct invitation. Unknown and blocked users are rejected.
+func addInitialMembers(ctx context.Context, tx pgx.Tx, groupID, ownerID string, memberIDs []string, memberLimit *int) ([]MemberAddResult, error) {
+	results := make([]MemberAddResult, 0, len(memberIDs))
+
+	requested := make([]string, 0, len(memberIDs))
+	seen := map[string]bool{ownerID: true}
+	for _, memberID := range memberIDs {
+		memberID = strings.ToLower(strings.TrimSpace(memberID))
+		if seen[memberID] {
+			continue
+		}
+		seen[memberID] = true
+		requested = append(requested, memberID)
 	}
-	if req.GroupID == "" {
-		http.Error(w, "group_id is required", http.StatusBadRequest)
-		return
+	if len(requested) == 0 {
+		return results, nil
 	}
 
-	_, err := db.Exec(r.Context(),
-		"INSERT INTO group_members (group_id, user_id) VALUES ($1, $2)",
-		req.GroupID, userID,
+	rows, err := tx.Query(ctx,
+		`SELECT u.id::text, COALESCE(f.status, '')
+		 FROM users u
+		 LEFT JOIN friendships f
+			ON f.user_a_id = LEAST(u.id, $1::uuid)
+			AND f.user_b_id = GREATEST(u.id, $1::uuid)
+		 WHERE u.id::text = ANY($2)`,
+		ownerID, requested,
 	)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			if pgErr.Code == "23505" {
-				http.Error(w, "You are already a member of this group", http.StatusConflict)
-				return
+		return nil, err
+	}
+	statuses := make(map[string]string)
+	for rows.Next() {
+		var id, status string
+		if err := rows.Scan(&id, &status); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		statuses[id] = status
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// the owner already holds one spot
+	spotsLeft := -1
+	if memberLimit != nil {
+		spotsLeft = *memberLimit - 1
+	}
+
+	for _, memberID := range requested {
+		status, exists := statuses[memberID]
+		result := MemberAddResult{UserID: memberID}
+
+		switch {
+		case !exists:
+			result.Result, result.Reason = MemberRejected, "user_not_found"
+		case status == "blocked":
+			// indistinguishable from an unknown user so blocks aren't revealed
+			result.Result, result.Reason = MemberRejected, "user_not_found"
+		case status == "accepted" && spotsLeft == 0:
+			result.Result, result.Reason = MemberRejected, "group_full"
+		case status == "accepted":
+			_, err := tx.Exec(ctx,
+				"INSERT INTO group_members (group_id, user_id) VALUES ($1, $2)",
+				groupID, memberID,
+			)
+			if err != nil {
+				return nil, err
 			}
-			if pgErr.Code == "23503" {
-				http.Error(w, "Group not found", http.StatusNotFound)
-				return
+			if err := recordFeedEvent(ctx, tx, EventMemberJoined, memberID, &groupID, nil, nil); err != nil {
+				return nil, err
+			}
+			if err := queueWebhookEvent(ctx, tx, groupID, webhooks.EventMemberJoined, memberID, nil); err != nil {
+				return nil, err
 			}
+			result.Result = MemberAdded
+			if spotsLeft > 0 {
+				spotsLeft--
+			}
+		default:
+			// a direct invitation, usable only by this user
+			maxUses := 1
+			if _, err := insertGroupInvite(ctx, tx, groupID, ownerID, &memberID, &maxUses, nil); err != nil {
+				return nil, err
+			}
+			result.Result, result.Reason = MemberInvited, "not_friends"
 		}
-		log.Printf("Join Group Error: %v", err)
-		http.Error(w, "Failed to join group", http.StatusInternalServerError)
-		return
+
+		results = append(results, result)
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":   "success",
-		"group_id": req.GroupID,
-		"user_id":  userID,
-	})
+	return results, nil
 }
 
 func handleGetGroupMembers(w http.ResponseWriter, r *http.Request) {
@@ -236,33 +330,19 @@ func handleGetGroupMembers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	groupID := r.URL.Query().Get("group_id")
-	if groupID == "" {
-		http.Error(w, "group_id is required", http.StatusBadRequest)
-		return
-	}
-
-	userID, ok := auth.GetUserID(r)
+	userID, _, ok := requireGroupRole(w, r, groupID, RoleMember)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	var exists bool
-	err := db.QueryRow(r.Context(),
-		"SELECT EXISTS(SELECT 1 FROM group_members WHERE group_id=$1 and user_id=$2)",
-		groupID, userID,
-	).Scan(&exists)
-	if err != nil || !exists {
-		http.Error(w, "Forbidden: You are not a member of this group", http.StatusForbidden)
 		return
 	}
 
 	rows, err := db.Query(r.Context(),
-		`SELECT u.id, u.name, u.picture
+		`SELECT u.id, u.name, u.picture, gm.role
 		 FROM group_members gm
 		 JOIN users u ON gm.user_id = u.id
-		 WHERE gm.group_id = $1`,
-		groupID,
+		 WHERE gm.group_id = $1
+		 AND `+notBlockedSQL("gm.user_id", "$2::uuid")+`
+		 ORDER BY gm.joined_at ASC`,
+		groupID, userID,
 	)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
@@ -270,11 +350,11 @@ func handleGetGroupMembers(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	members := make([]Friend, 0)
+	members := make([]GroupMember, 0)
 	for rows.Next() {
-		var m Friend
+		var m GroupMember
 		var pic *string
-		if err := rows.Scan(&m.ID, &m.Name, &pic); err != nil {
+		if err := rows.Scan(&m.ID, &m.Name, &pic, &m.Role); err != nil {
 			continue
 		}
 		if pic != nil {
@@ -311,17 +391,43 @@ func handleLeaveGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var ownerID string
-	err := db.QueryRow(r.Context(),
-		"SELECT owner_id FROM groups WHERE id=$1",
-		req.GroupID,
-	).Scan(&ownerID)
-	if err == nil && ownerID == userID {
-		http.Error(w, "Owner cannot leave group. Delete the group instead", http.StatusConflict)
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	role, err := getGroupRole(r.Context(), tx, req.GroupID, userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if role == "" {
+		http.Error(w, "You are not a member of this group", http.StatusNotFound)
 		return
 	}
 
-	_, err = db.Exec(r.Context(),
+	// an owner hands the group to the longest-standing admin on the way out
+	var newOwnerID string
+	if role == RoleOwner {
+		err := tx.QueryRow(r.Context(),
+			`SELECT user_id FROM group_members
+			 WHERE group_id = $1 AND role = 'admin'
+			 ORDER BY joined_at ASC
+			 LIMIT 1`,
+			req.GroupID,
+		).Scan(&newOwnerID)
+		if err == pgx.ErrNoRows {
+			http.Error(w, "Owner cannot leave without an admin. Transfer ownership or delete the group instead", http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	result, err := tx.Exec(r.Context(),
 		"DELETE FROM group_members WHERE group_id=$1 AND user_id=$2",
 		req.GroupID, userID,
 	)
@@ -330,8 +436,49 @@ func handleLeaveGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// events only go out for a membership that actually ended here, not one a
+	// concurrent request already removed
+	if result.RowsAffected() == 0 {
+		http.Error(w, "You are not a member of this group", http.StatusNotFound)
+		return
+	}
+
+	if err := recordFeedEvent(r.Context(), tx, EventMemberLeft, userID, &req.GroupID, nil, nil); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	err = queueWebhookEvent(r.Context(), tx, req.GroupID, webhooks.EventMemberLeft, userID,
+		map[string]any{"reason": "left"})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if newOwnerID != "" {
+		if err := setGroupOwner(r.Context(), tx, req.GroupID, newOwnerID); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				http.Error(w, "Your longest-standing admin already has a group with this name. Transfer ownership instead", http.StatusConflict)
+				return
+			}
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]string{"status": "left_group"}
+	if newOwnerID != "" {
+		response["new_owner_id"] = newOwnerID
+	}
+
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "left_group"})
+	json.NewEncoder(w).Encode(response)
 }
 
 func handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
@@ -341,20 +488,13 @@ func handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	groupID := r.URL.Query().Get("group_id")
-	if groupID == "" {
-		http.Error(w, "group_id is required", http.StatusBadRequest)
-		return
-	}
-
-	userID, ok := auth.GetUserID(r)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if _, _, ok := requireGroupRole(w, r, groupID, RoleOwner); !ok {
 		return
 	}
 
 	result, err := db.Exec(r.Context(),
-		"DELETE FROM groups WHERE id = $1 AND owner_id = $2",
-		groupID, userID,
+		"DELETE FROM groups WHERE id = $1",
+		groupID,
 	)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
@@ -362,7 +502,7 @@ func handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if result.RowsAffected() == 0 {
-		http.Error(w, "Group not found or you are not the owner", http.StatusNotFound)
+		http.Error(w, "Group not found", http.StatusNotFound)
 		return
 	}
 
@@ -379,29 +519,12 @@ func handleGetOwner(w http.ResponseWriter, r *http.Request) {
 	}
 
 	groupID := r.URL.Query().Get("group_id")
-	if groupID == "" {
-		http.Error(w, "group_id is required", http.StatusBadRequest)
-		return
-	}
-
-	userID, ok := auth.GetUserID(r)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	var exists bool
-	err := db.QueryRow(r.Context(),
-		"SELECT EXISTS(SELECT 1 FROM group_members WHERE group_id=$1 AND user_id=$2)",
-		groupID, userID,
-	).Scan(&exists)
-	if err != nil || !exists {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	if _, _, ok := requireGroupRole(w, r, groupID, RoleMember); !ok {
 		return
 	}
 
 	var ownerID string
-	err = db.QueryRow(r.Context(),
+	err := db.QueryRow(r.Context(),
 		"SELECT owner_id FROM groups WHERE id = $1",
 		groupID,
 	).Scan(&ownerID)
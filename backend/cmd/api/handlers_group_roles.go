@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/webhooks"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// setGroupOwner makes the member the group's owner, keeping groups.owner_id in sync.
+// Any previous owner row must already be demoted or removed.
+func setGroupOwner(ctx context.Context, q dbtx, groupID, userID string) error {
+	_, err := q.Exec(ctx,
+		"UPDATE group_members SET role = 'owner' WHERE group_id = $1 AND user_id = $2",
+		groupID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(ctx,
+		"UPDATE groups SET owner_id = $1 WHERE id = $2",
+		userID, groupID,
+	)
+	return err
+}
+
+type GroupMemberActionInput struct {
+	GroupID string `json:"group_id"`
+	UserID  string `json:"user_id"`
+}
+
+func decodeGroupMemberAction(w http.ResponseWriter, r *http.Request) (GroupMemberActionInput, bool) {
+	var req GroupMemberActionInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return req, false
+	}
+	req.GroupID = strings.TrimSpace(req.GroupID)
+	req.UserID = strings.TrimSpace(req.UserID)
+	if req.GroupID == "" || req.UserID == "" {
+		http.Error(w, "group_id and user_id are required", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// changeMemberRole moves a member between roles. Only the owner may promote or demote.
+func changeMemberRole(w http.ResponseWriter, r *http.Request, from, to, status string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, ok := decodeGroupMemberAction(w, r)
+	if !ok {
+		return
+	}
+
+	if _, _, ok := requireGroupRole(w, r, req.GroupID, RoleOwner); !ok {
+		return
+	}
+
+	result, err := db.Exec(r.Context(),
+		"UPDATE group_members SET role = $1 WHERE group_id::text = $2 AND user_id::text = $3 AND role = $4",
+		to, req.GroupID, req.UserID, from,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "No "+from+" with this id in the group", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  status,
+		"user_id": req.UserID,
+		"role":    to,
+	})
+}
+
+func handlePromoteMember(w http.ResponseWriter, r *http.Request) {
+	changeMemberRole(w, r, RoleMember, RoleAdmin, "member_promoted")
+}
+
+func handleDemoteMember(w http.ResponseWriter, r *http.Request) {
+	changeMemberRole(w, r, RoleAdmin, RoleMember, "member_demoted")
+}
+
+func handleKickMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, ok := decodeGroupMemberAction(w, r)
+	if !ok {
+		return
+	}
+
+	userID, role, ok := requireGroupRole(w, r, req.GroupID, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	if req.UserID == userID {
+		http.Error(w, "Use leave to remove yourself", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	// admins can remove members, the owner can also remove admins
+	result, err := tx.Exec(r.Context(),
+		`DELETE FROM group_members
+		 WHERE group_id::text = $1 AND user_id::text = $2
+		 AND role = ANY($3)`,
+		req.GroupID, req.UserID, kickableRoles(role),
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Member not found or outranks you", http.StatusNotFound)
+		return
+	}
+
+	err = recordFeedEvent(r.Context(), tx, EventMemberLeft, req.UserID, &req.GroupID, nil,
+		map[string]any{"removed_by": userID})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	err = queueWebhookEvent(r.Context(), tx, req.GroupID, webhooks.EventMemberLeft, req.UserID,
+		map[string]any{"reason": "removed", "removed_by": userID})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "member_removed",
+		"user_id": req.UserID,
+	})
+}
+
+func kickableRoles(role string) []string {
+	if role == RoleOwner {
+		return []string{RoleAdmin, RoleMember}
+	}
+	return []string{RoleMember}
+}
+
+func handleTransferOwnership(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, ok := decodeGroupMemberAction(w, r)
+	if !ok {
+		return
+	}
+
+	userID, _, ok := requireGroupRole(w, r, req.GroupID, RoleOwner)
+	if !ok {
+		return
+	}
+
+	if req.UserID == userID {
+		http.Error(w, "You already own this group", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	targetRole, err := getGroupRole(r.Context(), tx, req.GroupID, req.UserID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if targetRole == "" {
+		http.Error(w, "New owner must be a member of the group", http.StatusNotFound)
+		return
+	}
+
+	// the previous owner stays on as an admin
+	_, err = tx.Exec(r.Context(),
+		"UPDATE group_members SET role = 'admin' WHERE group_id = $1 AND user_id = $2",
+		req.GroupID, userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := setGroupOwner(r.Context(), tx, req.GroupID, req.UserID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			http.Error(w, "New owner already has a group with this name", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "ownership_transferred",
+		"group_id": req.GroupID,
+		"owner_id": req.UserID,
+	})
+}
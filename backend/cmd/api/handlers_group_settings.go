@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/webhooks"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const maxGroupDescriptionLength = 500
+
+var errGroupFull = errors.New("this group has reached its member limit")
+
+// ensureGroupCapacity checks that adding more members stays within the group's limit.
+// The group row is locked so concurrent joins cannot both take the last spot.
+func ensureGroupCapacity(ctx context.Context, tx pgx.Tx, groupID string, adding int) error {
+	var memberLimit *int
+	err := tx.QueryRow(ctx,
+		"SELECT member_limit FROM groups WHERE id::text = $1 FOR UPDATE",
+		groupID,
+	).Scan(&memberLimit)
+	if err != nil || memberLimit == nil {
+		return err
+	}
+
+	var memberCount int
+	err = tx.QueryRow(ctx,
+		"SELECT COUNT(*) FROM group_members WHERE group_id::text = $1",
+		groupID,
+	).Scan(&memberCount)
+	if err != nil {
+		return err
+	}
+
+	if memberCount+adding > *memberLimit {
+		return errGroupFull
+	}
+	return nil
+}
+
+// addGroupMember inserts a plain member after checking the group has room.
+func addGroupMember(ctx context.Context, tx pgx.Tx, groupID, userID string) error {
+	if err := ensureGroupCapacity(ctx, tx, groupID, 1); err != nil {
+		return err
+	}
+
+	// ON CONFLICT rather than catching the unique violation, which would abort tx
+	commandTag, err := tx.Exec(ctx,
+		`INSERT INTO group_members (group_id, user_id) VALUES ($1, $2)
+		 ON CONFLICT DO NOTHING`,
+		groupID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if commandTag.RowsAffected() == 0 {
+		return errAlreadyGroupMember
+	}
+	if err := recordFeedEvent(ctx, tx, EventMemberJoined, userID, &groupID, nil, nil); err != nil {
+		return err
+	}
+	return queueWebhookEvent(ctx, tx, groupID, webhooks.EventMemberJoined, userID, nil)
+}
+
+type GroupDetails struct {
+	ID               string  `json:"id"`
+	OwnerID          string  `json:"owner_id"`
+	Name             string  `json:"name"`
+	Description      *string `json:"description"`
+	AvatarURL        *string `json:"avatar_url"`
+	MemberLimit      *int    `json:"member_limit"`
+	MemberCount      int     `json:"member_count"`
+	ShowRetakes      bool    `json:"show_retakes"`
+	RequiresApproval bool    `json:"requires_approval"`
+	MyRole           string  `json:"my_role"`
+}
+
+func getGroupDetails(ctx context.Context, groupID, role string) (GroupDetails, error) {
+	g := GroupDetails{MyRole: role}
+	var avatarKey *string
+	err := db.QueryRow(ctx,
+		`SELECT g.id, g.owner_id, g.name, g.description, g.avatar_key, g.member_limit,
+			(SELECT COUNT(*) FROM group_members gm WHERE gm.group_id = g.id),
+			g.show_retakes, g.requires_approval
+		 FROM groups g
+		 WHERE g.id::text = $1`,
+		groupID,
+	).Scan(&g.ID, &g.OwnerID, &g.Name, &g.Description, &avatarKey, &g.MemberLimit, &g.MemberCount, &g.ShowRetakes, &g.RequiresApproval)
+	if err != nil {
+		return g, err
+	}
+	if avatarKey != nil {
+		url := publicObjectURL(s3Client.BucketName, *avatarKey)
+		g.AvatarURL = &url
+	}
+	return g, nil
+}
+
+func handleGroup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleGetGroup(w, r)
+	case http.MethodPatch:
+		handleUpdateGroup(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleGetGroup(w http.ResponseWriter, r *http.Request) {
+	groupID := r.PathValue("id")
+	_, role, ok := requireGroupRole(w, r, groupID, RoleMember)
+	if !ok {
+		return
+	}
+
+	group, err := getGroupDetails(r.Context(), groupID, role)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]GroupDetails{
+		"group": group,
+	})
+}
+
+// UpdateGroupRequest changes only the fields that are present. A member_limit of 0
+// removes the limit and an empty description clears it.
+type UpdateGroupRequest struct {
+	Name             *string `json:"name"`
+	Description      *string `json:"description"`
+	AvatarKey        *string `json:"avatar_key"`
+	MemberLimit      *int    `json:"member_limit"`
+	ShowRetakes      *bool   `json:"show_retakes"`
+	RequiresApproval *bool   `json:"requires_approval"`
+}
+
+func handleUpdateGroup(w http.ResponseWriter, r *http.Request) {
+	groupID := r.PathValue("id")
+	userID, role, ok := requireGroupRole(w, r, groupID, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var req UpdateGroupRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	sets := make([]string, 0)
+	changed := make([]string, 0)
+	args := []any{groupID}
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+		changed = append(changed, column)
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			http.Error(w, "Group name is required", http.StatusBadRequest)
+			return
+		}
+		if len(name) > 100 {
+			http.Error(w, "Group name too long (max 100 characters)", http.StatusBadRequest)
+			return
+		}
+		set("name", name)
+	}
+
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		if utf8.RuneCountInString(description) > maxGroupDescriptionLength {
+			http.Error(w, "Description too long (max 500 characters)", http.StatusBadRequest)
+			return
+		}
+		if description == "" {
+			set("description", nil)
+		} else {
+			set("description", description)
+		}
+	}
+
+	if req.AvatarKey != nil {
+		if !strings.HasPrefix(*req.AvatarKey, fmt.Sprintf("groups/%s/", groupID)) {
+			http.Error(w, "Invalid avatar key", http.StatusBadRequest)
+			return
+		}
+		set("avatar_key", *req.AvatarKey)
+	}
+
+	if req.MemberLimit != nil {
+		if *req.MemberLimit < 0 {
+			http.Error(w, "member_limit cannot be negative", http.StatusBadRequest)
+			return
+		}
+		if *req.MemberLimit == 0 {
+			set("member_limit", nil)
+		} else {
+			set("member_limit", *req.MemberLimit)
+		}
+	}
+
+	if req.ShowRetakes != nil {
+		set("show_retakes", *req.ShowRetakes)
+	}
+	if req.RequiresApproval != nil {
+		set("requires_approval", *req.RequiresApproval)
+	}
+
+	if len(sets) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	var oldAvatarKey *string
+	var memberCount int
+	err = tx.QueryRow(r.Context(),
+		`SELECT avatar_key, (SELECT COUNT(*) FROM group_members WHERE group_id = g.id)
+		 FROM groups g WHERE g.id::text = $1
+		 FOR UPDATE`,
+		groupID,
+	).Scan(&oldAvatarKey, &memberCount)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if req.MemberLimit != nil && *req.MemberLimit > 0 && *req.MemberLimit < memberCount {
+		http.Error(w, fmt.Sprintf("member_limit cannot be below the current member count (%d)", memberCount), http.StatusConflict)
+		return
+	}
+
+	_, err = tx.Exec(r.Context(),
+		"UPDATE groups SET "+strings.Join(sets, ", ")+", updated_at = NOW() WHERE id::text = $1",
+		args...,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			http.Error(w, "The owner already has a group with this name", http.StatusConflict)
+			return
+		}
+		log.Printf("Update Group Error: %v", err)
+		http.Error(w, "Failed to update group", http.StatusInternalServerError)
+		return
+	}
+
+	err = queueWebhookEvent(r.Context(), tx, groupID, webhooks.EventGroupUpdated, userID,
+		map[string]any{"fields": changed})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	if req.AvatarKey != nil && oldAvatarKey != nil && *oldAvatarKey != *req.AvatarKey {
+		if err := s3Client.DeleteObject(r.Context(), *oldAvatarKey); err != nil {
+			log.Printf("Failed to delete old avatar for group %s: %v", groupID, err)
+		}
+	}
+
+	group, err := getGroupDetails(r.Context(), groupID, role)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]GroupDetails{
+		"group": group,
+	})
+}
+
+func handleGetGroupAvatarUploadURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	groupID := r.PathValue("id")
+	if _, _, ok := requireGroupRole(w, r, groupID, RoleAdmin); !ok {
+		return
+	}
+
+	// a fresh key per upload so the old avatar can be deleted once replaced
+	avatarID, _ := uuid.NewV7()
+	key := fmt.Sprintf("groups/%s/avatar-%s.jpg", groupID, avatarID)
+
+	uploadURL, err := s3Client.GeneratePresignedUploadURL(key)
+	if err != nil {
+		http.Error(w, "Failed to generate upload ticket", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"upload_url": uploadURL,
+		"key":        key,
+	})
+}
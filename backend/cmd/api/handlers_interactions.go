@@ -0,0 +1,454 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxEmojiLength   = 16
+	maxCommentLength = 1000
+)
+
+// requirePhotoAccess resolves the photo from the path and checks the caller can see it.
+// It writes the error response itself and returns false when the request should stop.
+func requirePhotoAccess(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", "", false
+	}
+
+	photoID := r.PathValue("id")
+	if _, err := uuid.Parse(photoID); err != nil {
+		http.Error(w, "Invalid photo id", http.StatusBadRequest)
+		return "", "", false
+	}
+
+	visible, err := canViewPhoto(r.Context(), userID, photoID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return "", "", false
+	}
+	if !visible {
+		// indistinguishable from a missing photo on purpose
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return "", "", false
+	}
+
+	return userID, photoID, true
+}
+
+type ReactionInput struct {
+	Emoji string `json:"emoji"`
+}
+
+type ReactionSummary struct {
+	Emoji       string `json:"emoji"`
+	Count       int    `json:"count"`
+	ReactedByMe bool   `json:"reacted_by_me"`
+}
+
+func handlePhotoReactions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleListReactions(w, r)
+	case http.MethodPost:
+		handleAddReaction(w, r)
+	case http.MethodDelete:
+		handleRemoveReaction(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleListReactions(w http.ResponseWriter, r *http.Request) {
+	userID, photoID, ok := requirePhotoAccess(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := db.Query(r.Context(),
+		`SELECT emoji, COUNT(*), bool_or(user_id = $2)
+		 FROM photo_reactions
+		 WHERE photo_id = $1
+		 GROUP BY emoji
+		 ORDER BY COUNT(*) DESC, MIN(created_at) ASC`,
+		photoID, userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	reactions := make([]ReactionSummary, 0)
+	for rows.Next() {
+		var rs ReactionSummary
+		if err := rows.Scan(&rs.Emoji, &rs.Count, &rs.ReactedByMe); err != nil {
+			continue
+		}
+		reactions = append(reactions, rs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reactions": reactions,
+	})
+}
+
+func decodeReaction(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req ReactionInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return "", false
+	}
+	req.Emoji = strings.TrimSpace(req.Emoji)
+	if req.Emoji == "" {
+		http.Error(w, "emoji is required", http.StatusBadRequest)
+		return "", false
+	}
+	if len(req.Emoji) > maxEmojiLength {
+		http.Error(w, "emoji too long", http.StatusBadRequest)
+		return "", false
+	}
+	if !isEmoji(req.Emoji) {
+		http.Error(w, "emoji must be a single emoji", http.StatusBadRequest)
+		return "", false
+	}
+	return req.Emoji, true
+}
+
+func handleAddReaction(w http.ResponseWriter, r *http.Request) {
+	userID, photoID, ok := requirePhotoAccess(w, r)
+	if !ok {
+		return
+	}
+
+	emoji, ok := decodeReaction(w, r)
+	if !ok {
+		return
+	}
+
+	commandTag, err := db.Exec(r.Context(),
+		`INSERT INTO photo_reactions (photo_id, user_id, emoji) VALUES ($1, $2, $3)
+		 ON CONFLICT DO NOTHING`,
+		photoID, userID, emoji,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if commandTag.RowsAffected() == 0 {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		logFeedEvent(r.Context(), EventReactionAdded, userID, nil, &photoID, map[string]any{"emoji": emoji})
+		notifyReaction(r.Context(), userID, photoID, emoji)
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "reaction_added",
+		"emoji":  emoji,
+	})
+}
+
+func handleRemoveReaction(w http.ResponseWriter, r *http.Request) {
+	userID, photoID, ok := requirePhotoAccess(w, r)
+	if !ok {
+		return
+	}
+
+	emoji, ok := decodeReaction(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec(r.Context(),
+		"DELETE FROM photo_reactions WHERE photo_id = $1 AND user_id = $2 AND emoji = $3",
+		photoID, userID, emoji,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Reaction not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "reaction_removed",
+	})
+}
+
+type Comment struct {
+	ID        string  `json:"id"`
+	PhotoID   string  `json:"photo_id"`
+	ParentID  *string `json:"parent_id"`
+	UserID    string  `json:"user_id"`
+	Name      string  `json:"name"`
+	Picture   *string `json:"picture"`
+	Body      string  `json:"body"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type CommentInput struct {
+	Body     string  `json:"body"`
+	ParentID *string `json:"parent_id"`
+}
+
+func normalizeCommentBody(w http.ResponseWriter, body string) (string, bool) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		http.Error(w, "body is required", http.StatusBadRequest)
+		return "", false
+	}
+	if utf8.RuneCountInString(body) > maxCommentLength {
+		http.Error(w, "Comment too long (max 1000 characters)", http.StatusBadRequest)
+		return "", false
+	}
+	return body, true
+}
+
+func handlePhotoComments(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleListComments(w, r)
+	case http.MethodPost:
+		handleAddComment(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleListComments(w http.ResponseWriter, r *http.Request) {
+	_, photoID, ok := requirePhotoAccess(w, r)
+	if !ok {
+		return
+	}
+
+	// flat and oldest first; clients rebuild threads from parent_id
+	rows, err := db.Query(r.Context(),
+		`SELECT c.id, c.photo_id, c.parent_id, c.user_id, u.name, u.picture, c.body, c.created_at, c.updated_at
+		 FROM photo_comments c
+		 JOIN users u ON u.id = c.user_id
+		 WHERE c.photo_id = $1
+		 ORDER BY c.created_at ASC`,
+		photoID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	comments := make([]Comment, 0)
+	for rows.Next() {
+		var c Comment
+		var createdAt, updatedAt time.Time
+		if err := rows.Scan(&c.ID, &c.PhotoID, &c.ParentID, &c.UserID, &c.Name, &c.Picture, &c.Body, &createdAt, &updatedAt); err != nil {
+			continue
+		}
+		c.CreatedAt = createdAt.Format(time.RFC3339)
+		c.UpdatedAt = updatedAt.Format(time.RFC3339)
+		comments = append(comments, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"comments": comments,
+	})
+}
+
+func handleAddComment(w http.ResponseWriter, r *http.Request) {
+	userID, photoID, ok := requirePhotoAccess(w, r)
+	if !ok {
+		return
+	}
+
+	var req CommentInput
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	body, ok := normalizeCommentBody(w, req.Body)
+	if !ok {
+		return
+	}
+
+	if req.ParentID != nil {
+		var sameThread bool
+		err := db.QueryRow(r.Context(),
+			"SELECT EXISTS(SELECT 1 FROM photo_comments WHERE id::text = $1 AND photo_id = $2)",
+			*req.ParentID, photoID,
+		).Scan(&sameThread)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !sameThread {
+			http.Error(w, "Parent comment not found on this photo", http.StatusBadRequest)
+			return
+		}
+	}
+
+	commentID, _ := uuid.NewV7()
+
+	var c Comment
+	var createdAt, updatedAt time.Time
+	err := db.QueryRow(r.Context(),
+		`WITH inserted AS (
+			INSERT INTO photo_comments (id, photo_id, user_id, parent_id, body)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, photo_id, parent_id, user_id, body, created_at, updated_at
+		 )
+		 SELECT i.id, i.photo_id, i.parent_id, i.user_id, u.name, u.picture, i.body, i.created_at, i.updated_at
+		 FROM inserted i JOIN users u ON u.id = i.user_id`,
+		commentID, photoID, userID, req.ParentID, body,
+	).Scan(&c.ID, &c.PhotoID, &c.ParentID, &c.UserID, &c.Name, &c.Picture, &c.Body, &createdAt, &updatedAt)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	c.CreatedAt = createdAt.Format(time.RFC3339)
+	c.UpdatedAt = updatedAt.Format(time.RFC3339)
+
+	logFeedEvent(r.Context(), EventCommentAdded, userID, nil, &photoID,
+		map[string]any{"comment_id": c.ID, "parent_id": c.ParentID})
+	notifyComment(r.Context(), userID, photoID, c.ID, req.ParentID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]Comment{
+		"comment": c,
+	})
+}
+
+func handleComment(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPatch:
+		handleEditComment(w, r)
+	case http.MethodDelete:
+		handleDeleteComment(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleEditComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	commentID := r.PathValue("id")
+	if _, err := uuid.Parse(commentID); err != nil {
+		http.Error(w, "Invalid comment id", http.StatusBadRequest)
+		return
+	}
+
+	var req CommentInput
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	body, ok := normalizeCommentBody(w, req.Body)
+	if !ok {
+		return
+	}
+
+	var photoID string
+	err := db.QueryRow(r.Context(),
+		"SELECT photo_id FROM photo_comments WHERE id = $1 AND user_id = $2",
+		commentID, userID,
+	).Scan(&photoID)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// authors who lost access to the photo can no longer edit into it
+	visible, err := canViewPhoto(r.Context(), userID, photoID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+
+	_, err = db.Exec(r.Context(),
+		"UPDATE photo_comments SET body = $1, updated_at = NOW() WHERE id = $2",
+		body, commentID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "comment_updated",
+		"id":     commentID,
+		"body":   body,
+	})
+}
+
+func handleDeleteComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	commentID := r.PathValue("id")
+	if _, err := uuid.Parse(commentID); err != nil {
+		http.Error(w, "Invalid comment id", http.StatusBadRequest)
+		return
+	}
+
+	// authors can delete their comments and photo owners can moderate their photos
+	result, err := db.Exec(r.Context(),
+		`DELETE FROM photo_comments c
+		 USING photos p
+		 WHERE c.id = $1 AND p.id = c.photo_id
+		 AND (c.user_id = $2 OR p.user_id = $2)`,
+		commentID, userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "comment_deleted",
+		"id":     commentID,
+	})
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/ratelimit"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	inviteCodeLength   = 8
+	inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789" // no 0/O or 1/I/L
+)
+
+// joinLimiter caps join attempts per user so invite codes can't be guessed.
+var joinLimiter = ratelimit.New(20, time.Hour)
+
+var (
+	errInviteNotFound     = errors.New("invite not found")
+	errInviteUnusable     = errors.New("invite is expired, revoked or used up")
+	errInviteNotForYou    = errors.New("this invitation was sent to someone else")
+	errAlreadyGroupMember = errors.New("you are already a member of this group")
+	errJoinRequestExists  = errors.New("you already asked to join this group")
+)
+
+type GroupInvite struct {
+	ID        string  `json:"id"`
+	GroupID   string  `json:"group_id"`
+	Code      string  `json:"code"`
+	Link      string  `json:"link"`
+	CreatedBy string  `json:"created_by"`
+	InviteeID *string `json:"invitee_id"`
+	MaxUses   *int    `json:"max_uses"`
+	UseCount  int     `json:"use_count"`
+	ExpiresAt *string `json:"expires_at"`
+	CreatedAt string  `json:"created_at"`
+}
+
+func generateInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = inviteCodeAlphabet[int(b)%len(inviteCodeAlphabet)]
+	}
+	return string(buf), nil
+}
+
+func inviteLink(code string) string {
+	base := os.Getenv("INVITE_LINK_BASE")
+	if base == "" {
+		base = "snapshot://invite"
+	}
+	return strings.TrimSuffix(base, "/") + "/" + code
+}
+
+func scanGroupInvite(row pgx.Row) (GroupInvite, error) {
+	var inv GroupInvite
+	var expiresAt *time.Time
+	var createdAt time.Time
+	err := row.Scan(&inv.ID, &inv.GroupID, &inv.Code, &inv.CreatedBy, &inv.InviteeID, &inv.MaxUses, &inv.UseCount, &expiresAt, &createdAt)
+	if err != nil {
+		return inv, err
+	}
+	if expiresAt != nil {
+		formatted := expiresAt.UTC().Format(time.RFC3339)
+		inv.ExpiresAt = &formatted
+	}
+	inv.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	inv.Link = inviteLink(inv.Code)
+	return inv, nil
+}
+
+const groupInviteColumns = `id, group_id, code, created_by, invitee_id, max_uses, use_count, expires_at, created_at`
+
+func handleGroupInvites(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleListGroupInvites(w, r)
+	case http.MethodPost:
+		handleCreateGroupInvite(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type CreateInviteRequest struct {
+	InviteeID      *string `json:"invitee_id"`
+	ExpiresInHours *int    `json:"expires_in_hours"`
+	MaxUses        *int    `json:"max_uses"`
+}
+
+func handleCreateGroupInvite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := r.PathValue("id")
+
+	canManage, err := canManageGroup(r.Context(), groupID, userID)
+	if err != nil || !canManage {
+		http.Error(w, "Forbidden: only group owners and admins can invite", http.StatusForbidden)
+		return
+	}
+
+	var req CreateInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if req.MaxUses != nil && *req.MaxUses <= 0 {
+		http.Error(w, "max_uses must be positive", http.StatusBadRequest)
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInHours != nil {
+		if *req.ExpiresInHours <= 0 {
+			http.Error(w, "expires_in_hours must be positive", http.StatusBadRequest)
+			return
+		}
+		t := time.Now().UTC().Add(time.Duration(*req.ExpiresInHours) * time.Hour)
+		expiresAt = &t
+	}
+
+	// a direct invitation is for one person only
+	maxUses := req.MaxUses
+	if req.InviteeID != nil {
+		if *req.InviteeID == userID {
+			http.Error(w, "You cannot invite yourself", http.StatusBadRequest)
+			return
+		}
+		one := 1
+		maxUses = &one
+	}
+
+	if req.InviteeID != nil {
+		status, _, err := getFriendshipStatus(r.Context(), db, userID, *req.InviteeID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if status == "blocked" {
+			http.Error(w, "Invitee not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	invite, err := insertGroupInvite(r.Context(), db, groupID, userID, req.InviteeID, maxUses, expiresAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			http.Error(w, "Invitee not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Create Invite Error: %v", err)
+		http.Error(w, "Failed to create invite", http.StatusInternalServerError)
+		return
+	}
+
+	if req.InviteeID != nil {
+		notifyGroupInvite(r.Context(), userID, groupID, []string{*req.InviteeID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]GroupInvite{
+		"invite": invite,
+	})
+}
+
+func insertGroupInvite(ctx context.Context, q dbtx, groupID, createdBy string, inviteeID *string, maxUses *int, expiresAt *time.Time) (GroupInvite, error) {
+	code, err := generateInviteCode()
+	if err != nil {
+		return GroupInvite{}, err
+	}
+	inviteID, _ := uuid.NewV7()
+
+	return scanGroupInvite(q.QueryRow(ctx,
+		`INSERT INTO group_invites (id, group_id, code, created_by, invitee_id, max_uses, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING `+groupInviteColumns,
+		inviteID, groupID, code, createdBy, inviteeID, maxUses, expiresAt,
+	))
+}
+
+func handleListGroupInvites(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := r.PathValue("id")
+
+	canManage, err := canManageGroup(r.Context(), groupID, userID)
+	if err != nil || !canManage {
+		http.Error(w, "Forbidden: only group owners and admins can view invites", http.StatusForbidden)
+		return
+	}
+
+	rows, err := db.Query(r.Context(),
+		`SELECT `+groupInviteColumns+`
+		 FROM group_invites
+		 WHERE group_id::text = $1
+		 AND revoked_at IS NULL
+		 AND (expires_at IS NULL OR expires_at > NOW())
+		 AND (max_uses IS NULL OR use_count < max_uses)
+		 ORDER BY created_at DESC`,
+		groupID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	invites := make([]GroupInvite, 0)
+	for rows.Next() {
+		invite, err := scanGroupInvite(rows)
+		if err != nil {
+			continue
+		}
+		invites = append(invites, invite)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invites": invites,
+	})
+}
+
+func handleRevokeGroupInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := r.PathValue("id")
+	inviteID := r.PathValue("inviteId")
+
+	canManage, err := canManageGroup(r.Context(), groupID, userID)
+	if err != nil || !canManage {
+		http.Error(w, "Forbidden: only group owners and admins can revoke invites", http.StatusForbidden)
+		return
+	}
+
+	result, err := db.Exec(r.Context(),
+		`UPDATE group_invites SET revoked_at = NOW()
+		 WHERE id::text = $1 AND group_id::text = $2 AND revoked_at IS NULL`,
+		inviteID, groupID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Invite not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "invite_revoked",
+		"id":     inviteID,
+	})
+}
+
+type PendingInvitation struct {
+	InviteID  string  `json:"invite_id"`
+	GroupID   string  `json:"group_id"`
+	GroupName string  `json:"group_name"`
+	InviterID string  `json:"inviter_id"`
+	Inviter   string  `json:"inviter_name"`
+	ExpiresAt *string `json:"expires_at"`
+}
+
+// handleListMyInvitations lists direct invitations addressed to the caller.
+func handleListMyInvitations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := db.Query(r.Context(),
+		`SELECT gi.id, g.id, g.name, u.id, u.name, gi.expires_at
+		 FROM group_invites gi
+		 JOIN groups g ON g.id = gi.group_id
+		 JOIN users u ON u.id = gi.created_by
+		 WHERE gi.invitee_id = $1
+		 AND gi.revoked_at IS NULL
+		 AND (gi.expires_at IS NULL OR gi.expires_at > NOW())
+		 AND (gi.max_uses IS NULL OR gi.use_count < gi.max_uses)
+		 AND NOT EXISTS (SELECT 1 FROM group_members gm WHERE gm.group_id = g.id AND gm.user_id = $1)
+		 ORDER BY gi.created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	invitations := make([]PendingInvitation, 0)
+	for rows.Next() {
+		var pi PendingInvitation
+		var expiresAt *time.Time
+		if err := rows.Scan(&pi.InviteID, &pi.GroupID, &pi.GroupName, &pi.InviterID, &pi.Inviter, &expiresAt); err != nil {
+			continue
+		}
+		if expiresAt != nil {
+			formatted := expiresAt.UTC().Format(time.RFC3339)
+			pi.ExpiresAt = &formatted
+		}
+		invitations = append(invitations, pi)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invitations": invitations,
+	})
+}
+
+// inviteUsable reports whether an invite is still open: not revoked, not expired and
+// not used up.
+func inviteUsable(revokedAt, expiresAt *time.Time, maxUses *int, useCount int) bool {
+	return revokedAt == nil && (expiresAt == nil || expiresAt.After(time.Now())) && (maxUses == nil || useCount < *maxUses)
+}
+
+// lockUsableInvite locks an invite inside tx and reports whether it can still be used.
+func lockUsableInvite(ctx context.Context, tx pgx.Tx, inviteID string) (bool, error) {
+	var maxUses *int
+	var useCount int
+	var expiresAt, revokedAt *time.Time
+	err := tx.QueryRow(ctx,
+		`SELECT max_uses, use_count, expires_at, revoked_at
+		 FROM group_invites
+		 WHERE id::text = $1
+		 FOR UPDATE`,
+		inviteID,
+	).Scan(&maxUses, &useCount, &expiresAt, &revokedAt)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return inviteUsable(revokedAt, expiresAt, maxUses, useCount), nil
+}
+
+type redeemedInvite struct {
+	ID        string
+	GroupID   string
+	CreatedBy string
+	Pending   bool // a join request awaits approval instead of a membership
+}
+
+// redeemInvite validates an invite inside tx and adds the user to its group. Groups that
+// require approval get a pending join request instead, unless the invite was addressed
+// to the user directly. Exactly one of code or inviteID should be set.
+func redeemInvite(ctx context.Context, tx pgx.Tx, code, inviteID, userID string) (redeemedInvite, error) {
+	var inv redeemedInvite
+	var inviteeID *string
+	var maxUses *int
+	var useCount int
+	var expiresAt, revokedAt *time.Time
+	var requiresApproval bool
+
+	err := tx.QueryRow(ctx,
+		`SELECT gi.id, gi.group_id, gi.created_by, gi.invitee_id, gi.max_uses, gi.use_count,
+			gi.expires_at, gi.revoked_at, g.requires_approval
+		 FROM group_invites gi
+		 JOIN groups g ON g.id = gi.group_id
+		 WHERE ($1 <> '' AND gi.code = $1) OR ($2 <> '' AND gi.id::text = $2)
+		 FOR UPDATE OF gi`,
+		code, inviteID,
+	).Scan(&inv.ID, &inv.GroupID, &inv.CreatedBy, &inviteeID, &maxUses, &useCount, &expiresAt, &revokedAt, &requiresApproval)
+	if err == pgx.ErrNoRows {
+		return inv, errInviteNotFound
+	} else if err != nil {
+		return inv, err
+	}
+
+	if inviteeID != nil && *inviteeID != userID {
+		return inv, errInviteNotForYou
+	}
+	if blocked, err := isBlocked(ctx, tx, inv.CreatedBy, userID); err != nil {
+		return inv, err
+	} else if blocked {
+		return inv, errInviteNotFound
+	}
+	if !inviteUsable(revokedAt, expiresAt, maxUses, useCount) {
+		return inv, errInviteUnusable
+	}
+
+	if requiresApproval && inviteeID == nil {
+		if err := createJoinRequest(ctx, tx, inv.GroupID, userID, &inv.ID); err != nil {
+			return inv, err
+		}
+		inv.Pending = true
+		return inv, nil
+	}
+
+	if err := addInvitedMember(ctx, tx, inv.ID, inv.GroupID, userID, inv.CreatedBy); err != nil {
+		return inv, err
+	}
+
+	return inv, nil
+}
+
+// addInvitedMember inserts the membership and records who invited whom.
+func addInvitedMember(ctx context.Context, tx pgx.Tx, inviteID, groupID, userID, invitedBy string) error {
+	if err := addGroupMember(ctx, tx, groupID, userID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx,
+		"UPDATE group_invites SET use_count = use_count + 1 WHERE id = $1",
+		inviteID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO group_invite_redemptions (invite_id, user_id, invited_by)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT DO NOTHING`,
+		inviteID, userID, invitedBy,
+	)
+	return err
+}
+
+type JoinGroupRequest struct {
+	Code     string `json:"code"`
+	InviteID string `json:"invite_id"`
+}
+
+func handleJoinGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if allowed, retryAfter := joinLimiter.Allow(userID); !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "Too many join attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	var req JoinGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	req.InviteID = strings.TrimSpace(req.InviteID)
+	if req.Code == "" && req.InviteID == "" {
+		http.Error(w, "code or invite_id is required", http.StatusBadRequest)
+		return
+	}
+	if req.Code != "" && req.InviteID != "" {
+		http.Error(w, "Provide either code or invite_id, not both", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	invite, err := redeemInvite(r.Context(), tx, req.Code, req.InviteID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInviteNotFound):
+			http.Error(w, "Invite not found", http.StatusNotFound)
+		case errors.Is(err, errInviteNotForYou):
+			http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+		case errors.Is(err, errInviteUnusable):
+			http.Error(w, "Invite is expired, revoked or used up", http.StatusGone)
+		case errors.Is(err, errAlreadyGroupMember):
+			http.Error(w, "You are already a member of this group", http.StatusConflict)
+		case errors.Is(err, errJoinRequestExists):
+			http.Error(w, "You already asked to join this group", http.StatusConflict)
+		case errors.Is(err, errGroupFull):
+			http.Error(w, "This group is full", http.StatusConflict)
+		default:
+			log.Printf("Join Group Error: %v", err)
+			http.Error(w, "Failed to join group", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	if invite.Pending {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":   "join_requested",
+			"group_id": invite.GroupID,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     "success",
+		"group_id":   invite.GroupID,
+		"user_id":    userID,
+		"invited_by": invite.CreatedBy,
+	})
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// createJoinRequest records a pending request, refusing users who are already members.
+func createJoinRequest(ctx context.Context, tx pgx.Tx, groupID, userID string, inviteID *string) error {
+	var isMember bool
+	err := tx.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM group_members WHERE group_id = $1 AND user_id = $2)",
+		groupID, userID,
+	).Scan(&isMember)
+	if err != nil {
+		return err
+	}
+	if isMember {
+		return errAlreadyGroupMember
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO group_join_requests (group_id, user_id, invite_id)
+		 VALUES ($1, $2, $3)`,
+		groupID, userID, inviteID,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return errJoinRequestExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+type JoinRequestInput struct {
+	GroupID string `json:"group_id"`
+}
+
+// handleRequestToJoinGroup asks to join a group that accepts requests without an invite.
+func handleRequestToJoinGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req JoinRequestInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	req.GroupID = strings.TrimSpace(req.GroupID)
+	if req.GroupID == "" {
+		http.Error(w, "group_id is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	// groups that don't require approval are invite-only, so don't reveal they exist
+	var requiresApproval bool
+	err = tx.QueryRow(r.Context(),
+		"SELECT requires_approval FROM groups WHERE id::text = $1",
+		req.GroupID,
+	).Scan(&requiresApproval)
+	if err == pgx.ErrNoRows || (err == nil && !requiresApproval) {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := createJoinRequest(r.Context(), tx, req.GroupID, userID, nil); err != nil {
+		switch {
+		case errors.Is(err, errAlreadyGroupMember):
+			http.Error(w, "You are already a member of this group", http.StatusConflict)
+		case errors.Is(err, errJoinRequestExists):
+			http.Error(w, "You already asked to join this group", http.StatusConflict)
+		default:
+			log.Printf("Join Request Error: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "join_requested",
+		"group_id": req.GroupID,
+	})
+}
+
+type JoinRequestDecisionInput struct {
+	GroupID string `json:"group_id"`
+	UserID  string `json:"user_id"`
+}
+
+func decodeJoinRequestDecision(w http.ResponseWriter, r *http.Request) (string, JoinRequestDecisionInput, bool) {
+	var req JoinRequestDecisionInput
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", req, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return "", req, false
+	}
+	req.GroupID = strings.TrimSpace(req.GroupID)
+	req.UserID = strings.TrimSpace(req.UserID)
+	if req.GroupID == "" || req.UserID == "" {
+		http.Error(w, "group_id and user_id are required", http.StatusBadRequest)
+		return "", req, false
+	}
+
+	canManage, err := canManageGroup(r.Context(), req.GroupID, userID)
+	if err != nil || !canManage {
+		http.Error(w, "Forbidden: only group owners and admins can decide join requests", http.StatusForbidden)
+		return "", req, false
+	}
+
+	return userID, req, true
+}
+
+func handleApproveJoinRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	approverID, req, ok := decodeJoinRequestDecision(w, r)
+	if !ok {
+		return
+	}
+
+	// blocked users can't add each other, another admin has to decide
+	blocked, err := isBlocked(r.Context(), db, approverID, req.UserID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		http.Error(w, "No pending join request found from this user", http.StatusNotFound)
+		return
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	var inviteID, invitedBy *string
+	err = tx.QueryRow(r.Context(),
+		`DELETE FROM group_join_requests jr
+		 WHERE jr.group_id::text = $1 AND jr.user_id::text = $2
+		 RETURNING jr.invite_id, (SELECT created_by FROM group_invites WHERE id = jr.invite_id)`,
+		req.GroupID, req.UserID,
+	).Scan(&inviteID, &invitedBy)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "No pending join request found from this user", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// someone who joined another way since asking only needs the request cleared
+	var isMember bool
+	err = tx.QueryRow(r.Context(),
+		"SELECT EXISTS(SELECT 1 FROM group_members WHERE group_id::text = $1 AND user_id::text = $2)",
+		req.GroupID, req.UserID,
+	).Scan(&isMember)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if !isMember {
+		// the invite may have been revoked, expired or used up while the request
+		// waited; the approval still stands but the invite isn't credited
+		usable := false
+		if inviteID != nil {
+			usable, err = lockUsableInvite(r.Context(), tx, *inviteID)
+			if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if usable {
+			err = addInvitedMember(r.Context(), tx, *inviteID, req.GroupID, req.UserID, *invitedBy)
+		} else {
+			err = addGroupMember(r.Context(), tx, req.GroupID, req.UserID)
+		}
+		if errors.Is(err, errGroupFull) {
+			http.Error(w, "This group is full", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Approve Join Request Error: %v", err)
+			http.Error(w, "Failed to add member", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	if !isMember {
+		notifyGroupAdded(r.Context(), approverID, req.GroupID, []string{req.UserID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":      "join_request_approved",
+		"group_id":    req.GroupID,
+		"user_id":     req.UserID,
+		"approved_by": approverID,
+	})
+}
+
+func handleDenyJoinRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, req, ok := decodeJoinRequestDecision(w, r)
+	if !ok {
+		return
+	}
+
+	commandTag, err := db.Exec(r.Context(),
+		"DELETE FROM group_join_requests WHERE group_id::text = $1 AND user_id::text = $2",
+		req.GroupID, req.UserID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		http.Error(w, "No pending join request found from this user", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "join_request_denied",
+	})
+}
+
+func handleCancelJoinRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req JoinRequestInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	req.GroupID = strings.TrimSpace(req.GroupID)
+	if req.GroupID == "" {
+		http.Error(w, "group_id is required", http.StatusBadRequest)
+		return
+	}
+
+	commandTag, err := db.Exec(r.Context(),
+		"DELETE FROM group_join_requests WHERE group_id::text = $1 AND user_id = $2",
+		req.GroupID, userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		http.Error(w, "No pending join request found for this group", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "join_request_canceled",
+	})
+}
+
+type PendingJoinRequest struct {
+	GroupID   string `json:"group_id"`
+	GroupName string `json:"group_name"`
+	UserID    string `json:"user_id"`
+	Name      string `json:"name"`
+	Picture   string `json:"picture"`
+	CreatedAt string `json:"created_at"`
+}
+
+func scanJoinRequests(rows pgx.Rows) []PendingJoinRequest {
+	requests := make([]PendingJoinRequest, 0)
+	for rows.Next() {
+		var jr PendingJoinRequest
+		var pic *string
+		var createdAt time.Time
+		if err := rows.Scan(&jr.GroupID, &jr.GroupName, &jr.UserID, &jr.Name, &pic, &createdAt); err != nil {
+			continue
+		}
+		if pic != nil {
+			jr.Picture = *pic
+		}
+		jr.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		requests = append(requests, jr)
+	}
+	return requests
+}
+
+func handleListIncomingJoinRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := r.URL.Query().Get("group_id")
+	if groupID == "" {
+		http.Error(w, "group_id is required", http.StatusBadRequest)
+		return
+	}
+
+	canManage, err := canManageGroup(r.Context(), groupID, userID)
+	if err != nil || !canManage {
+		http.Error(w, "Forbidden: only group owners and admins can view join requests", http.StatusForbidden)
+		return
+	}
+
+	rows, err := db.Query(r.Context(),
+		`SELECT g.id, g.name, u.id, u.name, u.picture, jr.created_at
+		 FROM group_join_requests jr
+		 JOIN groups g ON g.id = jr.group_id
+		 JOIN users u ON u.id = jr.user_id
+		 WHERE jr.group_id::text = $1
+		 ORDER BY jr.created_at ASC`,
+		groupID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"incoming_requests": scanJoinRequests(rows),
+	})
+}
+
+func handleListOutgoingJoinRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := db.Query(r.Context(),
+		`SELECT g.id, g.name, u.id, u.name, u.picture, jr.created_at
+		 FROM group_join_requests jr
+		 JOIN groups g ON g.id = jr.group_id
+		 JOIN users u ON u.id = jr.user_id
+		 WHERE jr.user_id = $1
+		 ORDER BY jr.created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"outgoing_requests": scanJoinRequests(rows),
+	})
+}
@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
-	"github.com/Aayaan-Sahu/SNAPSHOT/cmd/utils"
 	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/slots"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/stats"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
@@ -26,29 +33,52 @@ func handleGetUploadURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	currentSlot := utils.GetCurrentHourSlot()
-	if !utils.IsValidSubmissionWindow(currentSlot) {
+	currentSlot := slots.GetCurrentHourSlot()
+	if !slots.IsValidSubmissionWindow(currentSlot) {
 		http.Error(w, "Submission window closed for this hour", http.StatusForbidden)
 		return
 	}
 
-	var exists bool
+	retake := r.URL.Query().Get("retake") == "true"
+
+	var retakeCount int
 	err := db.QueryRow(r.Context(),
-		"SELECT EXISTS(SELECT 1 FROM photos WHERE user_id=$1 AND hour_timestamp=$2)",
+		"SELECT retake_count FROM photos WHERE user_id=$1 AND hour_timestamp=$2",
 		userID, currentSlot,
-	).Scan(&exists)
-	if err != nil {
+	).Scan(&retakeCount)
+	exists := err == nil
+	if err != nil && err != pgx.ErrNoRows {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-	if exists {
+	if exists && !retake {
 		http.Error(w, "You have already taken a photo for this hour", http.StatusConflict)
 		return
 	}
+	if !exists {
+		deleted, err := slotPhotoDeleted(r.Context(), db, userID, currentSlot)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if deleted {
+			http.Error(w, "You deleted your photo for this hour", http.StatusConflict)
+			return
+		}
+	}
+	if retake && !exists {
+		http.Error(w, "No photo to retake for this hour", http.StatusNotFound)
+		return
+	}
+	if retake && retakeCount >= getMaxRetakes() {
+		http.Error(w, "No retakes left for this hour", http.StatusForbidden)
+		return
+	}
 
-	dateStr := currentSlot.Format("2006-01-02")
-	hourStr := currentSlot.Format("15")
-	key := fmt.Sprintf("uploads/%s/%s/%s.jpg", userID, dateStr, hourStr)
+	key := photoUploadKey(userID, currentSlot, 0)
+	if retake {
+		key = photoUploadKey(userID, currentSlot, retakeCount+1)
+	}
 
 	uploadURL, err := s3Client.GeneratePresignedUploadURL(key)
 	if err != nil {
@@ -63,9 +93,99 @@ func handleGetUploadURL(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// slotPhotoDeleted reports whether the user deleted a photo for slot while its window
+// was open, which uses the slot up.
+func slotPhotoDeleted(ctx context.Context, q dbtx, userID string, slot time.Time) (bool, error) {
+	var deleted bool
+	err := q.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM deleted_photo_slots WHERE user_id = $1 AND hour_timestamp = $2)",
+		userID, slot,
+	).Scan(&deleted)
+	return deleted, err
+}
+
+// photoUploadKey is the object key upload-url hands out for a slot. Each retake gets
+// a fresh key so deleting the replaced object never touches the new one, and no two
+// photos can ever share an object.
+func photoUploadKey(userID string, slot time.Time, retake int) string {
+	slot = slot.UTC()
+	key := fmt.Sprintf("uploads/%s/%s/%s", userID, slot.Format("2006-01-02"), slot.Format("15"))
+	if retake > 0 {
+		key += fmt.Sprintf("-retake%d", retake)
+	}
+	return key + ".jpg"
+}
+
+type SubmissionWindowResponse struct {
+	ServerTime     string `json:"server_time"`
+	CurrentSlot    string `json:"current_slot"`
+	WindowOpensAt  string `json:"window_opens_at"`
+	WindowClosesAt string `json:"window_closes_at"`
+	IsWindowOpen   bool   `json:"is_window_open"`
+	HasPosted      bool   `json:"has_posted"`
+	NextSlot       string `json:"next_slot"`
+}
+
+func handleGetSubmissionWindow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// derive everything from a single instant so the fields agree with each other
+	now := time.Now().UTC()
+	currentSlot := now.Truncate(time.Hour)
+	opensAt, closesAt := slots.GetSubmissionWindow(currentSlot)
+
+	var hasPosted bool
+	err := db.QueryRow(r.Context(),
+		"SELECT EXISTS(SELECT 1 FROM photos WHERE user_id=$1 AND hour_timestamp=$2)",
+		userID, currentSlot,
+	).Scan(&hasPosted)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SubmissionWindowResponse{
+		ServerTime:     now.Format(time.RFC3339Nano),
+		CurrentSlot:    currentSlot.Format(time.RFC3339),
+		WindowOpensAt:  opensAt.Format(time.RFC3339),
+		WindowClosesAt: closesAt.Format(time.RFC3339),
+		IsWindowOpen:   slots.IsWithinSubmissionWindow(currentSlot, now),
+		HasPosted:      hasPosted,
+		NextSlot:       slots.GetNextHourSlot(currentSlot).Format(time.RFC3339),
+	})
+}
+
 type ConfirmPhotoRequest struct {
-	Key           string `json:"key"`
-	SlotTimeStamp string `json:"slot_timestamp"`
+	Key           string   `json:"key"`
+	SlotTimeStamp string   `json:"slot_timestamp"`
+	Audience      string   `json:"audience"`  // all, groups, lists or close_friends
+	GroupIDs      []string `json:"group_ids"` // required when audience is groups
+	ListIDs       []string `json:"list_ids"`  // required when audience is lists
+	Caption       string   `json:"caption"`
+}
+
+const maxCaptionLength = 280
+
+// normalizeCaption trims a caption and maps an empty one to NULL.
+func normalizeCaption(caption string) (*string, bool) {
+	caption = strings.TrimSpace(caption)
+	if caption == "" {
+		return nil, true
+	}
+	if utf8.RuneCountInString(caption) > maxCaptionLength {
+		return nil, false
+	}
+	return &caption, true
 }
 
 func handleConfirmPhoto(w http.ResponseWriter, r *http.Request) {
@@ -87,7 +207,7 @@ func handleConfirmPhoto(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// security check
-	if !strings.Contains(req.Key, userID) {
+	if !strings.HasPrefix(req.Key, fmt.Sprintf("uploads/%s/", userID)) {
 		http.Error(w, "Invalid key: You can only confirm your own uploads", http.StatusForbidden)
 		return
 	}
@@ -99,12 +219,68 @@ func handleConfirmPhoto(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// only the slot whose window is open now, so captures can't be back-dated or
+	// confirmed ahead of time to pad streaks
+	currentSlot := slots.GetCurrentHourSlot()
+	if !slotTime.Equal(currentSlot) || !slots.IsValidSubmissionWindow(currentSlot) {
+		http.Error(w, "Submission window closed for this hour", http.StatusForbidden)
+		return
+	}
+	slotTime = currentSlot
+
+	if req.Key != photoUploadKey(userID, slotTime, 0) {
+		http.Error(w, "Invalid key: It was not issued for this slot", http.StatusBadRequest)
+		return
+	}
+
+	uploaded, err := s3Client.ObjectExists(r.Context(), req.Key)
+	if err != nil {
+		log.Printf("Confirm Photo Error: %v", err)
+		http.Error(w, "Storage error", http.StatusInternalServerError)
+		return
+	}
+	if !uploaded {
+		http.Error(w, "The photo has not been uploaded", http.StatusBadRequest)
+		return
+	}
+
+	audience, audienceIDs, err := normalizeAudience(req.Audience, req.GroupIDs, req.ListIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	caption, ok := normalizeCaption(req.Caption)
+	if !ok {
+		http.Error(w, "Caption too long (max 280 characters)", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	deleted, err := slotPhotoDeleted(r.Context(), tx, userID, slotTime)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if deleted {
+		http.Error(w, "You deleted your photo for this hour", http.StatusConflict)
+		return
+	}
+
 	// database insert
-	_, err = db.Exec(r.Context(),
-		`INSERT INTO photos (user_id, s3_key, bucket, hour_timestamp)
-		 VALUES ($1, $2, $3, $4)`,
-		userID, req.Key, s3Client.BucketName, slotTime,
-	)
+	var photoID string
+	err = tx.QueryRow(r.Context(),
+		`INSERT INTO photos (user_id, s3_key, bucket, hour_timestamp, caption)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING id`,
+		userID, req.Key, s3Client.BucketName, slotTime, caption,
+	).Scan(&photoID)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
@@ -115,14 +291,408 @@ func handleConfirmPhoto(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	audience, audienceIDs, err = resolveCloseFriendsAudience(r.Context(), tx, userID, audience, audienceIDs)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err := setPhotoAudience(r.Context(), tx, userID, photoID, audience, audienceIDs); err != nil {
+		if isAudienceError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := stats.RecordCapture(r.Context(), tx, userID, slotTime); err != nil {
+		log.Printf("Confirm Photo Error: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	err = recordFeedEvent(r.Context(), tx, EventPhotoPosted, userID, nil, &photoID,
+		map[string]any{"slot": slotTime.Format(time.RFC3339)})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := queuePhotoWebhookEvents(r.Context(), tx, userID, photoID, slotTime); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	notifyNewPhoto(r.Context(), userID, photoID)
+
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "photo_confirmed"})
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "photo_confirmed",
+		"photo_id": photoID,
+	})
+}
+
+// getMaxRetakes reads how many times a photo may be replaced within its window.
+func getMaxRetakes() int {
+	maxRetakes, err := strconv.Atoi(os.Getenv("MAX_PHOTO_RETAKES"))
+	if err != nil || maxRetakes < 0 {
+		return 1
+	}
+	return maxRetakes
+}
+
+type RetakePhotoRequest struct {
+	Key           string `json:"key"`
+	SlotTimeStamp string `json:"slot_timestamp"`
+}
+
+func handleRetakePhoto(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req RetakePhotoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if !strings.HasPrefix(req.Key, fmt.Sprintf("uploads/%s/", userID)) {
+		http.Error(w, "Invalid key: You can only confirm your own uploads", http.StatusForbidden)
+		return
+	}
+
+	slotTime, err := time.Parse(time.RFC3339, req.SlotTimeStamp)
+	if err != nil {
+		http.Error(w, "Invalid timestamp format", http.StatusBadRequest)
+		return
+	}
+
+	// retakes are only allowed while the slot is still open
+	if !slotTime.Equal(slots.GetCurrentHourSlot()) || !slots.IsValidSubmissionWindow(slotTime) {
+		http.Error(w, "Submission window closed for this hour", http.StatusForbidden)
+		return
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	var photoID, oldKey string
+	var retakeCount int
+	err = tx.QueryRow(r.Context(),
+		`SELECT id, s3_key, retake_count FROM photos
+		 WHERE user_id = $1 AND hour_timestamp = $2
+		 FOR UPDATE`,
+		userID, slotTime,
+	).Scan(&photoID, &oldKey, &retakeCount)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "No photo to retake for this hour", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if retakeCount >= getMaxRetakes() {
+		http.Error(w, "No retakes left for this hour", http.StatusForbidden)
+		return
+	}
+	// only the key upload-url issued for this retake, never another photo's object
+	if req.Key != photoUploadKey(userID, slotTime, retakeCount+1) {
+		http.Error(w, "Invalid key: It was not issued for this retake", http.StatusBadRequest)
+		return
+	}
+
+	uploaded, err := s3Client.ObjectExists(r.Context(), req.Key)
+	if err != nil {
+		log.Printf("Retake Photo Error: %v", err)
+		http.Error(w, "Storage error", http.StatusInternalServerError)
+		return
+	}
+	if !uploaded {
+		http.Error(w, "The retaken photo has not been uploaded", http.StatusBadRequest)
+		return
+	}
+
+	_, err = tx.Exec(r.Context(),
+		`UPDATE photos
+		 SET s3_key = $1, bucket = $2, retake_count = retake_count + 1, retaken_at = NOW()
+		 WHERE id = $3`,
+		req.Key, s3Client.BucketName, photoID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	// the row now points at the new object, so the old one is safe to remove
+	if err := s3Client.DeleteObject(r.Context(), oldKey); err != nil {
+		log.Printf("Failed to delete replaced photo %s: %v", photoID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "photo_retaken",
+		"photo_id": photoID,
+	})
+}
+
+func handlePhoto(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPatch:
+		handleUpdatePhoto(w, r)
+	case http.MethodDelete:
+		handleDeletePhoto(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// UpdatePhotoRequest changes only the fields that are present.
+type UpdatePhotoRequest struct {
+	Audience *string  `json:"audience"`
+	GroupIDs []string `json:"group_ids"`
+	ListIDs  []string `json:"list_ids"`
+	Caption  *string  `json:"caption"`
+}
+
+func handleUpdatePhoto(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	photoID := r.PathValue("id")
+	if _, err := uuid.Parse(photoID); err != nil {
+		http.Error(w, "Invalid photo id", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdatePhotoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if req.Audience == nil && req.Caption == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	var audience string
+	var audienceIDs []string
+	if req.Audience != nil {
+		var err error
+		audience, audienceIDs, err = normalizeAudience(*req.Audience, req.GroupIDs, req.ListIDs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var caption *string
+	if req.Caption != nil {
+		if caption, ok = normalizeCaption(*req.Caption); !ok {
+			http.Error(w, "Caption too long (max 280 characters)", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	var currentCaption *string
+	err = tx.QueryRow(r.Context(),
+		"SELECT caption FROM photos WHERE id = $1 AND user_id = $2 FOR UPDATE",
+		photoID, userID,
+	).Scan(&currentCaption)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Caption != nil {
+		_, err := tx.Exec(r.Context(),
+			"UPDATE photos SET caption = $1 WHERE id = $2",
+			caption, photoID,
+		)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		currentCaption = caption
+	}
+
+	if req.Audience != nil {
+		var err error
+		audience, audienceIDs, err = resolveCloseFriendsAudience(r.Context(), tx, userID, audience, audienceIDs)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if err := setPhotoAudience(r.Context(), tx, userID, photoID, audience, audienceIDs); err != nil {
+			if isAudienceError(err) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		err := tx.QueryRow(r.Context(),
+			`SELECT pa.audience, COALESCE(
+				(SELECT array_agg(pag.group_id::text) FROM photo_audience_groups pag WHERE pag.photo_id = pa.photo_id),
+				(SELECT array_agg(pal.list_id::text) FROM photo_audience_lists pal WHERE pal.photo_id = pa.photo_id),
+				'{}')
+			 FROM photo_audiences pa
+			 WHERE pa.photo_id = $1`,
+			photoID,
+		).Scan(&audience, &audienceIDs)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	groupIDs, listIDs := []string{}, []string{}
+	switch audience {
+	case AudienceGroups:
+		groupIDs = audienceIDs
+	case AudienceLists:
+		listIDs = audienceIDs
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":        photoID,
+		"audience":  audience,
+		"group_ids": groupIDs,
+		"list_ids":  listIDs,
+		"caption":   currentCaption,
+	})
+}
+
+func handleDeletePhoto(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	photoID := r.PathValue("id")
+	if _, err := uuid.Parse(photoID); err != nil {
+		http.Error(w, "Invalid photo id", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	var key string
+	var slot time.Time
+	err = tx.QueryRow(r.Context(),
+		"DELETE FROM photos WHERE id = $1 AND user_id = $2 RETURNING s3_key, hour_timestamp",
+		photoID, userID,
+	).Scan(&key, &slot)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// the slot stays used up so deleting can't reset its retakes
+	if slots.IsValidSubmissionWindow(slot) {
+		_, err := tx.Exec(r.Context(),
+			`INSERT INTO deleted_photo_slots (user_id, hour_timestamp) VALUES ($1, $2)
+			 ON CONFLICT DO NOTHING`,
+			userID, slot,
+		)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// a hole in the history can split a streak, so recompute rather than decrement
+	if err := stats.Rebuild(r.Context(), tx, userID); err != nil {
+		log.Printf("Delete Photo Error: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s3Client.DeleteObject(r.Context(), key); err != nil {
+		log.Printf("Failed to delete photo object %s: %v", photoID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "photo_deleted",
+		"id":     photoID,
+	})
+}
+
+func publicObjectURL(bucket, key string) string {
+	s3Endpoint := os.Getenv("S3_ENDPOINT")
+	if strings.Contains(s3Endpoint, "localhost") {
+		return fmt.Sprintf("%s/%s/%s", s3Endpoint, bucket, key)
+	}
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", bucket, key)
 }
 
 type PhotoSlot struct {
-	Hour   int     `json:"hour"`
-	Status string  `json:"status"` // taken or missed
-	URL    *string `json:"url"`
+	Hour    int     `json:"hour"`
+	Status  string  `json:"status"` // taken or missed
+	URL     *string `json:"url"`
+	PhotoID *string `json:"photo_id"`
+	Retaken *bool   `json:"retaken,omitempty"` // only set when the group shows retakes
+	Caption *string `json:"caption"`
+
+	ReactionCount int `json:"reaction_count"`
+	CommentCount  int `json:"comment_count"`
 }
 
 type UserTimeline struct {
@@ -151,22 +721,25 @@ func handleGetSlideshow(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// ensure requester is in group
-	var inGroup bool
+	var showRetakes bool
 	err := db.QueryRow(r.Context(),
-		"SELECT EXISTS(SELECT 1 FROM group_members WHERE group_id=$1 AND user_id=$2)",
+		`SELECT g.show_retakes
+		 FROM groups g JOIN group_members gm ON gm.group_id = g.id
+		 WHERE g.id=$1 AND gm.user_id=$2`,
 		groupID, userID,
-	).Scan(&inGroup)
-	if err != nil || !inGroup {
+	).Scan(&showRetakes)
+	if err != nil {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
-	// get all group members
+	// get all group members, leaving out anyone blocked in either direction
 	rows, err := db.Query(r.Context(),
 		`SELECT u.id, u.name, u.picture
 		 FROM group_members gm JOIN users u ON gm.user_id = u.id
-		 WHERE gm.group_id=$1`,
-		groupID,
+		 WHERE gm.group_id=$1
+		 AND `+notBlockedSQL("gm.user_id", "$2::uuid"),
+		groupID, userID,
 	)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
@@ -193,11 +766,14 @@ func handleGetSlideshow(w http.ResponseWriter, r *http.Request) {
 
 	// get all rows where user_id in memberIDs and hour_timestamp is today
 	photoRows, err := db.Query(r.Context(),
-		`SELECT user_id, hour_timestamp, s3_key, bucket
-		 FROM photos
-		 WHERE user_id = ANY($1)
-		 AND hour_timestamp >= $2 AND hour_timestamp < $3`,
-		memberIDs, startOfDay, endOfDay,
+		`SELECT p.id, p.user_id, p.hour_timestamp, p.s3_key, p.bucket, p.retake_count, p.caption,
+			(SELECT COUNT(*) FROM photo_reactions pr WHERE pr.photo_id = p.id),
+			(SELECT COUNT(*) FROM photo_comments pc WHERE pc.photo_id = p.id)
+		 FROM photos p
+		 WHERE p.user_id = ANY($1)
+		 AND p.hour_timestamp >= $2 AND p.hour_timestamp < $3
+		 AND `+photoVisibleSQL("p", "$4", "$5"),
+		memberIDs, startOfDay, endOfDay, groupID, userID,
 	)
 	if err != nil {
 		http.Error(w, "Database error fetching photos", http.StatusInternalServerError)
@@ -205,42 +781,62 @@ func handleGetSlideshow(w http.ResponseWriter, r *http.Request) {
 	}
 	defer photoRows.Close()
 
-	// map of userID -> hour -> publicURL
-	photoMap := make(map[string]map[int]string)
-	s3Endpoint := os.Getenv("S3_ENDPOINT")
+	type slotPhoto struct {
+		id            string
+		url           string
+		retaken       bool
+		caption       *string
+		reactionCount int
+		commentCount  int
+	}
+
+	// map of userID -> hour -> photo
+	photoMap := make(map[string]map[int]slotPhoto)
 
 	for photoRows.Next() {
-		var uid string
+		var photoID, uid string
 		var ts time.Time
 		var key, bucket string
-		if err := photoRows.Scan(&uid, &ts, &key, &bucket); err != nil {
+		var retakeCount, reactionCount, commentCount int
+		var caption *string
+		if err := photoRows.Scan(&photoID, &uid, &ts, &key, &bucket, &retakeCount, &caption, &reactionCount, &commentCount); err != nil {
 			continue
 		}
 
 		hour := ts.Hour()
 
-		var publicURL string
-		if strings.Contains(s3Endpoint, "localhost") {
-			publicURL = fmt.Sprintf("%s/%s/%s", s3Endpoint, bucket, key)
-		} else {
-			publicURL = fmt.Sprintf("https://%s.s3.amazonaws.com/%s", bucket, key)
-		}
+		publicURL := publicObjectURL(bucket, key)
 
 		if photoMap[uid] == nil {
-			photoMap[uid] = make(map[int]string)
+			photoMap[uid] = make(map[int]slotPhoto)
+		}
+		photoMap[uid][hour] = slotPhoto{
+			id:            photoID,
+			url:           publicURL,
+			retaken:       retakeCount > 0,
+			caption:       caption,
+			reactionCount: reactionCount,
+			commentCount:  commentCount,
 		}
-		photoMap[uid][hour] = publicURL
 	}
 
 	// for each member, fill in their timeline
 	for i, member := range members {
 		for h := 0; h < 24; h++ {
-			if url, found := photoMap[member.UserID][h]; found {
-				members[i].Timeline[h] = PhotoSlot{
-					Hour:   h,
-					Status: "taken",
-					URL:    &url,
+			if photo, found := photoMap[member.UserID][h]; found {
+				slot := PhotoSlot{
+					Hour:          h,
+					Status:        "taken",
+					URL:           &photo.url,
+					PhotoID:       &photo.id,
+					Caption:       photo.caption,
+					ReactionCount: photo.reactionCount,
+					CommentCount:  photo.commentCount,
+				}
+				if showRetakes {
+					slot.Retaken = &photo.retaken
 				}
+				members[i].Timeline[h] = slot
 			} else {
 				members[i].Timeline[h] = PhotoSlot{
 					Hour:   h,
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/jackc/pgx/v5"
+)
+
+const quietHoursLayout = "15:04"
+
+type NotificationPreferences struct {
+	FriendRequests  bool    `json:"friend_requests"`
+	GroupInvites    bool    `json:"group_invites"`
+	NewPhotos       bool    `json:"new_photos"`
+	Reminders       bool    `json:"reminders"`
+	Comments        bool    `json:"comments"`
+	Reactions       bool    `json:"reactions"`
+	Nudges          bool    `json:"nudges"`
+	DailyDigest     bool    `json:"daily_digest"`      // email, off unless turned on
+	QuietHoursStart *string `json:"quiet_hours_start"` // HH:MM in the user's timezone
+	QuietHoursEnd   *string `json:"quiet_hours_end"`
+	Timezone        string  `json:"timezone"`
+}
+
+func getNotificationPreferences(ctx context.Context, userID string) (NotificationPreferences, error) {
+	var p NotificationPreferences
+	err := db.QueryRow(ctx,
+		`SELECT COALESCE(np.friend_requests, TRUE), COALESCE(np.group_invites, TRUE),
+			COALESCE(np.new_photos, TRUE), COALESCE(np.reminders, TRUE),
+			COALESCE(np.comments, TRUE), COALESCE(np.reactions, TRUE), COALESCE(np.nudges, TRUE),
+			COALESCE(np.daily_digest, FALSE),
+			to_char(np.quiet_hours_start, 'HH24:MI'), to_char(np.quiet_hours_end, 'HH24:MI'),
+			COALESCE(u.timezone, 'UTC')
+		 FROM users u
+		 LEFT JOIN notification_preferences np ON np.user_id = u.id
+		 WHERE u.id = $1`,
+		userID,
+	).Scan(&p.FriendRequests, &p.GroupInvites, &p.NewPhotos, &p.Reminders, &p.Comments, &p.Reactions, &p.Nudges, &p.DailyDigest,
+		&p.QuietHoursStart, &p.QuietHoursEnd, &p.Timezone)
+	return p, err
+}
+
+func handleNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleGetNotificationPreferences(w, r)
+	case http.MethodPatch:
+		handleUpdateNotificationPreferences(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	prefs, err := getNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]NotificationPreferences{
+		"preferences": prefs,
+	})
+}
+
+// UpdateNotificationPreferencesRequest changes only the fields that are present.
+// Quiet hours are set as a pair; empty strings for both turn them off.
+type UpdateNotificationPreferencesRequest struct {
+	FriendRequests  *bool   `json:"friend_requests"`
+	GroupInvites    *bool   `json:"group_invites"`
+	NewPhotos       *bool   `json:"new_photos"`
+	Reminders       *bool   `json:"reminders"`
+	Comments        *bool   `json:"comments"`
+	Reactions       *bool   `json:"reactions"`
+	Nudges          *bool   `json:"nudges"`
+	DailyDigest     *bool   `json:"daily_digest"`
+	QuietHoursStart *string `json:"quiet_hours_start"`
+	QuietHoursEnd   *string `json:"quiet_hours_end"`
+	Timezone        *string `json:"timezone"`
+}
+
+// isKnownTimezone reports whether both Go and Postgres understand the IANA name, since
+// the timezone is used by each. "Local" would mean the server's own zone.
+func isKnownTimezone(ctx context.Context, name string) (bool, error) {
+	if name == "" || name == "Local" {
+		return false, nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return false, nil
+	}
+
+	var known bool
+	err := db.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM pg_timezone_names WHERE name = $1)",
+		name,
+	).Scan(&known)
+	return known, err
+}
+
+func handleUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req UpdateNotificationPreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if (req.QuietHoursStart == nil) != (req.QuietHoursEnd == nil) {
+		http.Error(w, "quiet_hours_start and quiet_hours_end must be set together", http.StatusBadRequest)
+		return
+	}
+	setQuietHours := req.QuietHoursStart != nil
+	var quietStart, quietEnd *string
+	if setQuietHours && (*req.QuietHoursStart != "" || *req.QuietHoursEnd != "") {
+		for _, v := range []string{*req.QuietHoursStart, *req.QuietHoursEnd} {
+			if _, err := time.Parse(quietHoursLayout, v); err != nil {
+				http.Error(w, "Quiet hours must be HH:MM", http.StatusBadRequest)
+				return
+			}
+		}
+		quietStart, quietEnd = req.QuietHoursStart, req.QuietHoursEnd
+	}
+
+	if req.Timezone != nil {
+		valid, err := isKnownTimezone(r.Context(), *req.Timezone)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !valid {
+			http.Error(w, "Unknown timezone", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	_, err = tx.Exec(r.Context(),
+		`INSERT INTO notification_preferences (user_id) VALUES ($1)
+		 ON CONFLICT DO NOTHING`,
+		userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec(r.Context(),
+		`UPDATE notification_preferences SET
+			friend_requests = COALESCE($2, friend_requests),
+			group_invites = COALESCE($3, group_invites),
+			new_photos = COALESCE($4, new_photos),
+			reminders = COALESCE($5, reminders),
+			comments = COALESCE($6, comments),
+			reactions = COALESCE($7, reactions),
+			nudges = COALESCE($8, nudges),
+			daily_digest = COALESCE($9, daily_digest),
+			quiet_hours_start = CASE WHEN $10 THEN $11::time ELSE quiet_hours_start END,
+			quiet_hours_end = CASE WHEN $10 THEN $12::time ELSE quiet_hours_end END,
+			updated_at = NOW()
+		 WHERE user_id = $1`,
+		userID, req.FriendRequests, req.GroupInvites, req.NewPhotos, req.Reminders, req.Comments, req.Reactions, req.Nudges, req.DailyDigest,
+		setQuietHours, quietStart, quietEnd,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Timezone != nil {
+		_, err = tx.Exec(r.Context(), "UPDATE users SET timezone = $1 WHERE id = $2", *req.Timezone, userID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	handleGetNotificationPreferences(w, r)
+}
+
+type GroupNotificationSettings struct {
+	GroupID string `json:"group_id"`
+	Muted   bool   `json:"muted"`
+}
+
+func handleGroupNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	groupID := r.PathValue("id")
+	userID, _, ok := requireGroupRole(w, r, groupID, RoleMember)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPatch {
+		var req struct {
+			Muted *bool `json:"muted"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid body", http.StatusBadRequest)
+			return
+		}
+		if req.Muted == nil {
+			http.Error(w, "muted is required", http.StatusBadRequest)
+			return
+		}
+
+		_, err := db.Exec(r.Context(),
+			`INSERT INTO group_notification_settings (user_id, group_id, muted) VALUES ($1, $2, $3)
+			 ON CONFLICT (user_id, group_id) DO UPDATE SET muted = EXCLUDED.muted, updated_at = NOW()`,
+			userID, groupID, *req.Muted,
+		)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	settings := GroupNotificationSettings{GroupID: groupID}
+	err := db.QueryRow(r.Context(),
+		"SELECT muted FROM group_notification_settings WHERE user_id = $1 AND group_id::text = $2",
+		userID, groupID,
+	).Scan(&settings.Muted)
+	if err != nil && err != pgx.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]GroupNotificationSettings{
+		"settings": settings,
+	})
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+)
+
+const (
+	defaultNotificationLimit = 30
+	maxNotificationLimit     = 100
+)
+
+type NotificationActor struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Picture string `json:"picture"`
+}
+
+type NotificationGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type InboxNotification struct {
+	ID        string             `json:"id"`
+	Type      string             `json:"type"`
+	Title     string             `json:"title"`
+	Body      string             `json:"body"`
+	Actor     *NotificationActor `json:"actor"`
+	Group     *NotificationGroup `json:"group"`
+	PhotoID   *string            `json:"photo_id"`
+	Read      bool               `json:"read"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+func unreadNotificationCount(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := db.QueryRow(ctx,
+		"SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL",
+		userID,
+	).Scan(&count)
+	return count, err
+}
+
+func handleListNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, offset, err := parsePage(r, defaultNotificationLimit, maxNotificationLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	// deleting an actor, group or photo deletes its entries, so set IDs always join
+	rows, err := db.Query(r.Context(),
+		`SELECT n.id, n.type, n.title, n.body,
+			a.id::text, COALESCE(a.name, ''), COALESCE(a.picture, ''),
+			g.id::text, g.name,
+			n.photo_id::text, n.read_at IS NOT NULL, n.created_at
+		 FROM notifications n
+		 LEFT JOIN users a ON a.id = n.actor_id
+		 LEFT JOIN groups g ON g.id = n.group_id
+		 WHERE n.user_id = $1
+		 AND (NOT $2 OR n.read_at IS NULL)
+		 ORDER BY n.created_at DESC, n.id DESC
+		 LIMIT $3 OFFSET $4`,
+		userID, unreadOnly, limit+1, offset,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := make([]InboxNotification, 0, limit)
+	for rows.Next() {
+		var n InboxNotification
+		var actorID, groupID, groupName *string
+		var actorName, actorPicture string
+		if err := rows.Scan(&n.ID, &n.Type, &n.Title, &n.Body,
+			&actorID, &actorName, &actorPicture,
+			&groupID, &groupName,
+			&n.PhotoID, &n.Read, &n.CreatedAt); err != nil {
+			continue
+		}
+		if actorID != nil {
+			n.Actor = &NotificationActor{ID: *actorID, Name: actorName, Picture: actorPicture}
+		}
+		if groupID != nil && groupName != nil {
+			n.Group = &NotificationGroup{ID: *groupID, Name: *groupName}
+		}
+		items = append(items, n)
+	}
+
+	var nextOffset *int
+	if len(items) > limit {
+		items = items[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	unread, err := unreadNotificationCount(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notifications": items,
+		"unread_count":  unread,
+		"next_offset":   nextOffset,
+	})
+}
+
+func handleUnreadNotificationCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	unread, err := unreadNotificationCount(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{
+		"unread_count": unread,
+	})
+}
+
+func handleMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	notificationID := r.PathValue("id")
+
+	// marking an already read entry again keeps its original read time
+	tag, err := db.Exec(r.Context(),
+		`UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+		 WHERE id::text = $1 AND user_id = $2`,
+		notificationID, userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+
+	unread, err := unreadNotificationCount(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{
+		"unread_count": unread,
+	})
+}
+
+func handleMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tag, err := db.Exec(r.Context(),
+		"UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{
+		"marked":       tag.RowsAffected(),
+		"unread_count": 0,
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/slots"
+)
+
+const maxNudgeTargets = 100
+
+// NudgeRequest picks who to nudge. Leaving UserIDs empty nudges every member who
+// hasn't posted yet.
+type NudgeRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+func handleNudgeGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	groupID := r.PathValue("id")
+	userID, _, ok := requireGroupRole(w, r, groupID, RoleMember)
+	if !ok {
+		return
+	}
+
+	var req NudgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	req.UserIDs = uniqueIDs(req.UserIDs)
+	if len(req.UserIDs) > maxNudgeTargets {
+		http.Error(w, fmt.Sprintf("At most %d users can be nudged at once", maxNudgeTargets), http.StatusBadRequest)
+		return
+	}
+
+	slot := slots.GetCurrentHourSlot()
+	if !slots.IsValidSubmissionWindow(slot) {
+		http.Error(w, "No submission window is open", http.StatusConflict)
+		return
+	}
+
+	// anyone already nudged by this user this slot, even from another group, is
+	// quietly skipped
+	rows, err := db.Query(r.Context(),
+		`SELECT gm.user_id::text
+		 FROM group_members gm
+		 WHERE gm.group_id::text = $1
+		 AND gm.user_id <> $2
+		 AND (cardinality($4::text[]) = 0 OR gm.user_id::text = ANY($4::text[]))
+		 AND NOT EXISTS (
+			SELECT 1 FROM photos p
+			WHERE p.user_id = gm.user_id AND p.hour_timestamp = $3
+		 )
+		 AND NOT EXISTS (
+			SELECT 1 FROM nudges n
+			WHERE n.sender_id = $2 AND n.recipient_id = gm.user_id AND n.slot = $3
+		 )
+		 AND `+notBlockedSQL("gm.user_id", "$2::uuid"),
+		groupID, userID, slot, req.UserIDs,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	candidates := make([]string, 0)
+	for rows.Next() {
+		var recipientID string
+		if err := rows.Scan(&recipientID); err != nil {
+			continue
+		}
+		candidates = append(candidates, recipientID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// members who turned nudges off, muted the group or are in quiet hours won't
+	// hear about it, so they don't use up the sender's nudge either
+	n := nudgeNotification(r.Context(), userID, groupID, slot)
+	if dispatcher != nil {
+		candidates, err = dispatcher.Recipients(r.Context(), candidates, n)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// the primary key allows one nudge per sender and recipient each slot, which
+	// also settles concurrent requests
+	rows, err = db.Query(r.Context(),
+		`INSERT INTO nudges (group_id, sender_id, recipient_id, slot)
+		 SELECT $1, $2, recipient_id, $3
+		 FROM unnest($4::uuid[]) AS recipient_id
+		 ON CONFLICT DO NOTHING
+		 RETURNING recipient_id::text`,
+		groupID, userID, slot, candidates,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	nudged := make([]string, 0)
+	for rows.Next() {
+		var recipientID string
+		if err := rows.Scan(&recipientID); err != nil {
+			continue
+		}
+		nudged = append(nudged, recipientID)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	notifyUsers(r.Context(), nudged, n)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"slot":   slot.Format(time.RFC3339),
+		"nudged": nudged,
+	})
+}
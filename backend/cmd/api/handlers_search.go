@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/ratelimit"
+)
+
+const (
+	minSearchQueryLength = 2
+	defaultSearchLimit   = 20
+	maxSearchLimit       = 50
+)
+
+// searchLimiter caps directory lookups per user to make scraping impractical.
+var searchLimiter = ratelimit.New(30, time.Minute)
+
+type UserSearchResult struct {
+	ID           string  `json:"id"`
+	Name         string  `json:"name"`
+	Handle       *string `json:"handle"`
+	Picture      *string `json:"picture"`
+	Relationship string  `json:"relationship"` // none, friend, pending_incoming or pending_outgoing
+}
+
+// escapeLike makes user input safe to embed in a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func relationshipFor(userID string, status, requesterID *string) string {
+	switch {
+	case status == nil:
+		return "none"
+	case *status == "accepted":
+		return "friend"
+	case *requesterID == userID:
+		return "pending_outgoing"
+	default:
+		return "pending_incoming"
+	}
+}
+
+// parsePage reads limit and offset query parameters, clamping limit to max.
+func parsePage(r *http.Request, defaultLimit, max int) (int, int, error) {
+	limit, offset := defaultLimit, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("invalid limit")
+		}
+		limit = min(n, max)
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("invalid offset")
+		}
+		offset = n
+	}
+	return limit, offset, nil
+}
+
+func handleSearchUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if allowed, retryAfter := searchLimiter.Allow(userID); !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "Too many searches, try again shortly", http.StatusTooManyRequests)
+		return
+	}
+
+	query := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+	query = strings.TrimPrefix(query, "@")
+	if len([]rune(query)) < minSearchQueryLength {
+		http.Error(w, fmt.Sprintf("q must be at least %d characters", minSearchQueryLength), http.StatusBadRequest)
+		return
+	}
+
+	limit, offset, err := parsePage(r, defaultSearchLimit, maxSearchLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// prefix matches on the name, any word of the name or the handle rank first,
+	// then trigram similarity picks up typos. One extra row tells us if there's more.
+	rows, err := db.Query(r.Context(),
+		`SELECT u.id, COALESCE(u.name, ''), u.handle, u.picture, f.status, f.requester_id::text
+		 FROM users u
+		 LEFT JOIN friendships f
+			ON f.user_a_id = LEAST(u.id, $1::uuid)
+			AND f.user_b_id = GREATEST(u.id, $1::uuid)
+		 WHERE u.id <> $1::uuid
+		 AND (f.status IS NULL OR f.status <> 'blocked')
+		 AND (
+			lower(u.name) LIKE $3::text || '%'
+			OR lower(u.name) LIKE '% ' || $3::text || '%'
+			OR u.handle LIKE $3::text || '%'
+			OR lower(u.name) % $2::text
+			OR u.handle % $2::text
+		 )
+		 ORDER BY
+			(lower(u.name) LIKE $3::text || '%' OR u.handle LIKE $3::text || '%') DESC,
+			GREATEST(similarity(lower(u.name), $2::text), similarity(COALESCE(u.handle, ''), $2::text)) DESC,
+			u.id
+		 LIMIT $4 OFFSET $5`,
+		userID, query, escapeLike(query), limit+1, offset,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	results := make([]UserSearchResult, 0, limit)
+	for rows.Next() {
+		var u UserSearchResult
+		var status, requesterID *string
+		if err := rows.Scan(&u.ID, &u.Name, &u.Handle, &u.Picture, &status, &requesterID); err != nil {
+			continue
+		}
+		u.Relationship = relationshipFor(userID, status, requesterID)
+		results = append(results, u)
+	}
+
+	var nextOffset *int
+	if len(results) > limit {
+		results = results[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users":       results,
+		"next_offset": nextOffset,
+	})
+}
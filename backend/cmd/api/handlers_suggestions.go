@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	defaultSuggestionLimit = 20
+	maxSuggestionLimit     = 50
+	// how many mutual friends are returned with each suggestion as evidence
+	suggestionEvidenceSize = 3
+)
+
+type FriendSuggestion struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Handle        *string  `json:"handle"`
+	Picture       *string  `json:"picture"`
+	MutualCount   int      `json:"mutual_count"`
+	SharedGroups  int      `json:"shared_groups"`
+	MutualFriends []Friend `json:"mutual_friends"`
+}
+
+// friendSuggestionsQuery ranks users by mutual friends, then shared groups. Each
+// friendship is reached through an index on its own side of the canonical pair,
+// rather than an OR across both columns.
+const friendSuggestionsQuery = `
+	WITH my_friends AS (
+		SELECT user_b_id AS id FROM friendships WHERE user_a_id = $1 AND status = 'accepted'
+		UNION ALL
+		SELECT user_a_id FROM friendships WHERE user_b_id = $1 AND status = 'accepted'
+	), mutuals AS (
+		SELECT f.user_b_id AS candidate, mf.id AS via
+		FROM my_friends mf JOIN friendships f ON f.user_a_id = mf.id AND f.status = 'accepted'
+		UNION ALL
+		SELECT f.user_a_id, mf.id
+		FROM my_friends mf JOIN friendships f ON f.user_b_id = mf.id AND f.status = 'accepted'
+	), by_mutuals AS (
+		SELECT candidate, COUNT(*) AS mutual_count, array_agg(via) AS mutual_ids
+		FROM mutuals
+		GROUP BY candidate
+	), by_groups AS (
+		SELECT other.user_id AS candidate, COUNT(*) AS shared_groups
+		FROM group_members mine
+		JOIN group_members other ON other.group_id = mine.group_id AND other.user_id <> mine.user_id
+		WHERE mine.user_id = $1
+		GROUP BY other.user_id
+	), candidates AS (
+		SELECT COALESCE(m.candidate, g.candidate) AS id,
+			COALESCE(m.mutual_count, 0) AS mutual_count,
+			COALESCE(g.shared_groups, 0) AS shared_groups,
+			COALESCE(m.mutual_ids, '{}') AS mutual_ids
+		FROM by_mutuals m
+		FULL JOIN by_groups g ON g.candidate = m.candidate
+	)
+	SELECT u.id, COALESCE(u.name, ''), u.handle, u.picture, c.mutual_count, c.shared_groups,
+		COALESCE(ev.ids, '{}'), COALESCE(ev.names, '{}'), COALESCE(ev.pictures, '{}')
+	FROM candidates c
+	JOIN users u ON u.id = c.id
+	LEFT JOIN LATERAL (
+		SELECT array_agg(e.id::text) AS ids, array_agg(COALESCE(e.name, '')) AS names, array_agg(COALESCE(e.picture, '')) AS pictures
+		FROM (
+			SELECT mu.id, mu.name, mu.picture FROM users mu
+			WHERE mu.id = ANY(c.mutual_ids)
+			ORDER BY lower(mu.name)
+			LIMIT $4
+		) e
+	) ev ON true
+	WHERE c.id <> $1
+	-- friends, pending requests and blocks in either direction all have a row
+	AND NOT EXISTS (
+		SELECT 1 FROM friendships f
+		WHERE f.user_a_id = LEAST(c.id, $1) AND f.user_b_id = GREATEST(c.id, $1)
+	)
+	AND NOT EXISTS (
+		SELECT 1 FROM friend_suggestion_dismissals d
+		WHERE d.user_id = $1 AND d.dismissed_id = c.id
+	)
+	ORDER BY c.mutual_count DESC, c.shared_groups DESC, u.id
+	LIMIT $2 OFFSET $3`
+
+func handleFriendSuggestions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, offset, err := parsePage(r, defaultSuggestionLimit, maxSuggestionLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := db.Query(r.Context(), friendSuggestionsQuery, userID, limit+1, offset, suggestionEvidenceSize)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	suggestions := make([]FriendSuggestion, 0, limit)
+	for rows.Next() {
+		var s FriendSuggestion
+		var ids, names, pictures []string
+		if err := rows.Scan(&s.ID, &s.Name, &s.Handle, &s.Picture, &s.MutualCount, &s.SharedGroups, &ids, &names, &pictures); err != nil {
+			continue
+		}
+		s.MutualFriends = make([]Friend, len(ids))
+		for i := range ids {
+			s.MutualFriends[i] = Friend{ID: ids[i], Name: names[i], Picture: pictures[i]}
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	var nextOffset *int
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"suggestions": suggestions,
+		"next_offset": nextOffset,
+	})
+}
+
+type DismissSuggestionInput struct {
+	TargetID string `json:"target_id"`
+}
+
+func handleDismissSuggestion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req DismissSuggestionInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	req.TargetID = strings.TrimSpace(req.TargetID)
+	if req.TargetID == "" {
+		http.Error(w, "target_id is required", http.StatusBadRequest)
+		return
+	}
+	if req.TargetID == userID {
+		http.Error(w, "You cannot dismiss yourself", http.StatusBadRequest)
+		return
+	}
+
+	// dismissals are permanent, dismissing twice is a no-op
+	_, err := db.Exec(r.Context(),
+		`INSERT INTO friend_suggestion_dismissals (user_id, dismissed_id)
+		 VALUES ($1, $2)
+		 ON CONFLICT DO NOTHING`,
+		userID, req.TargetID,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && (pgErr.Code == "23503" || pgErr.Code == "22P02") {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":    "suggestion_dismissed",
+		"target_id": req.TargetID,
+	})
+}
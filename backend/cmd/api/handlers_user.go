@@ -2,11 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"regexp"
+	"strings"
 
-	"github.com/Aayaan-Sahu/SNAPSHOT/cmd/utils"
 	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type UserResponse struct {
@@ -14,9 +17,21 @@ type UserResponse struct {
 	Email   string  `json:"email"`
 	Name    string  `json:"name"`
 	Picture *string `json:"picture"`
+	Handle  *string `json:"handle"`
 }
 
 func handleMe(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleGetMe(w, r)
+	case http.MethodPatch:
+		handleUpdateMe(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleGetMe(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r)
 	if !ok {
 		http.Error(w, "User ID not found in context", http.StatusInternalServerError)
@@ -25,9 +40,9 @@ func handleMe(w http.ResponseWriter, r *http.Request) {
 
 	var user UserResponse
 	err := db.QueryRow(r.Context(),
-		"SELECT id, email, name, picture FROM users WHERE id = $1",
+		"SELECT id, email, name, picture, handle FROM users WHERE id = $1",
 		userID,
-	).Scan(&user.ID, &user.Email, &user.Name, &user.Picture)
+	).Scan(&user.ID, &user.Email, &user.Name, &user.Picture, &user.Handle)
 	if err == pgx.ErrNoRows {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
@@ -42,29 +57,49 @@ func handleMe(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func handleGetUserStatus(w http.ResponseWriter, r *http.Request) {
+var handlePattern = regexp.MustCompile(`^[a-z0-9_.]{3,30}$`)
+
+// UpdateMeRequest changes only the fields that are present.
+type UpdateMeRequest struct {
+	Handle *string `json:"handle"`
+}
+
+func handleUpdateMe(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	currentSlot := utils.GetCurrentHourSlot()
+	var req UpdateMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if req.Handle == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
 
-	if !utils.IsValidSubmissionWindow(currentSlot) {
-		json.NewEncoder(w).Encode(map[string]bool{"has_posted": false})
+	handle := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(*req.Handle), "@"))
+	if !handlePattern.MatchString(handle) {
+		http.Error(w, "Handle must be 3-30 characters of letters, numbers, '_' or '.'", http.StatusBadRequest)
 		return
 	}
 
-	var exists bool
-	err := db.QueryRow(r.Context(),
-		"SELECT EXISTS(SELECT 1 FROM photos WHERE user_id=$1 AND hour_timestamp=$2)",
-		userID, currentSlot,
-	).Scan(&exists)
+	_, err := db.Exec(r.Context(),
+		"UPDATE users SET handle = $1 WHERE id = $2",
+		handle, userID,
+	)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			http.Error(w, "Handle is already taken", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]bool{"has_posted": exists})
+	handleGetMe(w, r)
 }
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/webhooks"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxGroupWebhooks        = 10
+	maxWebhookURLLength     = 2048
+	minWebhookSecretLength  = 16
+	maxWebhookSecretLength  = 256
+	defaultDeliveryLimit    = 20
+	maxDeliveryLimit        = 100
+	webhookSecretRandomSize = 32
+)
+
+// GroupWebhook is returned without its secret, except right after the secret was
+// set so the owner can copy it once.
+type GroupWebhook struct {
+	ID        string    `json:"id"`
+	GroupID   string    `json:"group_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	EventID        int64           `json:"event_id"`
+	Type           string          `json:"type"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	LastResponse   *string         `json:"last_response"`
+	RedeliveryOf   *string         `json:"redelivery_of"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+const groupWebhookColumns = "id, group_id::text, url, events, active, created_at, updated_at"
+
+func scanGroupWebhook(row pgx.Row) (GroupWebhook, error) {
+	var wh GroupWebhook
+	err := row.Scan(&wh.ID, &wh.GroupID, &wh.URL, &wh.Events, &wh.Active, &wh.CreatedAt, &wh.UpdatedAt)
+	return wh, err
+}
+
+const webhookDeliveryColumns = `id, event_id, type, status, attempts,
+	CASE WHEN status = 'pending' THEN next_attempt_at END,
+	last_attempt_at, last_status_code, last_error, last_response, redelivery_of::text,
+	payload, created_at, delivered_at`
+
+func scanWebhookDelivery(row pgx.Row) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	err := row.Scan(&d.ID, &d.EventID, &d.Type, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastAttemptAt, &d.LastStatusCode, &d.LastError, &d.LastResponse, &d.RedeliveryOf,
+		&d.Payload, &d.CreatedAt, &d.DeliveredAt)
+	return d, err
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, webhookSecretRandomSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// validateWebhookEvents drops duplicates and rejects unknown event types. An empty
+// list subscribes to everything.
+func validateWebhookEvents(events []string) ([]string, bool) {
+	unique := uniqueIDs(events)
+	for _, e := range unique {
+		if !slices.Contains(webhooks.EventTypes, e) {
+			return nil, false
+		}
+	}
+	return unique, true
+}
+
+func validateWebhookSecret(secret string) bool {
+	return len(secret) >= minWebhookSecretLength && len(secret) <= maxWebhookSecretLength
+}
+
+// requireGroupWebhook checks the caller owns the group in the path and the webhook
+// in the path belongs to it.
+func requireGroupWebhook(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	groupID := r.PathValue("id")
+	if _, _, ok := requireGroupRole(w, r, groupID, RoleOwner); !ok {
+		return "", "", false
+	}
+
+	webhookID := r.PathValue("webhookId")
+	var exists bool
+	err := db.QueryRow(r.Context(),
+		"SELECT EXISTS(SELECT 1 FROM group_webhooks WHERE id::text = $1 AND group_id::text = $2)",
+		webhookID, groupID,
+	).Scan(&exists)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return "", "", false
+	}
+	if !exists {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return "", "", false
+	}
+	return groupID, webhookID, true
+}
+
+func handleGroupWebhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleListGroupWebhooks(w, r)
+	case http.MethodPost:
+		handleCreateGroupWebhook(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleListGroupWebhooks(w http.ResponseWriter, r *http.Request) {
+	groupID := r.PathValue("id")
+	if _, _, ok := requireGroupRole(w, r, groupID, RoleOwner); !ok {
+		return
+	}
+
+	rows, err := db.Query(r.Context(),
+		"SELECT "+groupWebhookColumns+" FROM group_webhooks WHERE group_id::text = $1 ORDER BY created_at",
+		groupID,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	hooks := make([]GroupWebhook, 0)
+	for rows.Next() {
+		wh, err := scanGroupWebhook(rows)
+		if err != nil {
+			continue
+		}
+		hooks = append(hooks, wh)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhooks":    hooks,
+		"event_types": webhooks.EventTypes,
+	})
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is generated when left empty
+	Secret string `json:"secret"`
+}
+
+func handleCreateGroupWebhook(w http.ResponseWriter, r *http.Request) {
+	groupID := r.PathValue("id")
+	userID, _, ok := requireGroupRole(w, r, groupID, RoleOwner)
+	if !ok {
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.URL) > maxWebhookURLLength {
+		http.Error(w, "url is too long", http.StatusBadRequest)
+		return
+	}
+	if err := webhooks.ValidateURL(req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	events, ok := validateWebhookEvents(req.Events)
+	if !ok {
+		http.Error(w, "Unknown event type", http.StatusBadRequest)
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		generated, err := generateWebhookSecret()
+		if err != nil {
+			http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+			return
+		}
+		secret = generated
+	} else if !validateWebhookSecret(secret) {
+		http.Error(w, "secret must be between 16 and 256 characters", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(r.Context()) }()
+
+	// lock the group so concurrent creates can't both pass the limit
+	var count int
+	err = tx.QueryRow(r.Context(),
+		`SELECT (SELECT COUNT(*) FROM group_webhooks WHERE group_id = g.id)
+		 FROM groups g WHERE g.id::text = $1
+		 FOR UPDATE`,
+		groupID,
+	).Scan(&count)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxGroupWebhooks {
+		http.Error(w, "This group already has the maximum number of webhooks", http.StatusConflict)
+		return
+	}
+
+	webhookID, _ := uuid.NewV7()
+	wh, err := scanGroupWebhook(tx.QueryRow(r.Context(),
+		`INSERT INTO group_webhooks (id, group_id, url, secret, events, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING `+groupWebhookColumns,
+		webhookID, groupID, req.URL, secret, events, userID,
+	))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	wh.Secret = secret
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]GroupWebhook{
+		"webhook": wh,
+	})
+}
+
+func handleGroupWebhook(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPatch:
+		handleUpdateGroupWebhook(w, r)
+	case http.MethodDelete:
+		handleDeleteGroupWebhook(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// UpdateWebhookRequest changes only the fields that are present. RotateSecret
+// generates a new secret, which is returned once.
+type UpdateWebhookRequest struct {
+	URL          *string   `json:"url"`
+	Events       *[]string `json:"events"`
+	Active       *bool     `json:"active"`
+	Secret       *string   `json:"secret"`
+	RotateSecret bool      `json:"rotate_secret"`
+}
+
+func handleUpdateGroupWebhook(w http.ResponseWriter, r *http.Request) {
+	_, webhookID, ok := requireGroupWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if req.URL != nil {
+		if len(*req.URL) > maxWebhookURLLength {
+			http.Error(w, "url is too long", http.StatusBadRequest)
+			return
+		}
+		if err := webhooks.ValidateURL(*req.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var events []string
+	if req.Events != nil {
+		var ok bool
+		if events, ok = validateWebhookEvents(*req.Events); !ok {
+			http.Error(w, "Unknown event type", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Secret != nil && req.RotateSecret {
+		http.Error(w, "Set either secret or rotate_secret", http.StatusBadRequest)
+		return
+	}
+	var secret *string
+	if req.Secret != nil {
+		if !validateWebhookSecret(*req.Secret) {
+			http.Error(w, "secret must be between 16 and 256 characters", http.StatusBadRequest)
+			return
+		}
+		secret = req.Secret
+	}
+	if req.RotateSecret {
+		generated, err := generateWebhookSecret()
+		if err != nil {
+			http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+			return
+		}
+		secret = &generated
+	}
+
+	wh, err := scanGroupWebhook(db.QueryRow(r.Context(),
+		`UPDATE group_webhooks SET
+			url = COALESCE($2, url),
+			events = CASE WHEN $3 THEN $4::text[] ELSE events END,
+			active = COALESCE($5, active),
+			secret = COALESCE($6, secret),
+			updated_at = NOW()
+		 WHERE id::text = $1
+		 RETURNING `+groupWebhookColumns,
+		webhookID, req.URL, req.Events != nil, events, req.Active, secret,
+	))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if secret != nil {
+		wh.Secret = *secret
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]GroupWebhook{
+		"webhook": wh,
+	})
+}
+
+func handleDeleteGroupWebhook(w http.ResponseWriter, r *http.Request) {
+	_, webhookID, ok := requireGroupWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	// deliveries go with it, including any still pending
+	_, err := db.Exec(r.Context(), "DELETE FROM group_webhooks WHERE id::text = $1", webhookID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "webhook_deleted",
+	})
+}
+
+func handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, webhookID, ok := requireGroupWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := parsePage(r, defaultDeliveryLimit, maxDeliveryLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && status != "pending" && status != "succeeded" && status != "failed" {
+		http.Error(w, "status must be pending, succeeded or failed", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := db.Query(r.Context(),
+		`SELECT `+webhookDeliveryColumns+`
+		 FROM webhook_deliveries
+		 WHERE webhook_id::text = $1
+		 AND ($2 = '' OR status = $2)
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT $3 OFFSET $4`,
+		webhookID, status, limit+1, offset,
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	deliveries := make([]WebhookDelivery, 0, limit)
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	var nextOffset *int
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries":  deliveries,
+		"next_offset": nextOffset,
+	})
+}
+
+// handleRedeliverWebhook queues a fresh copy of a past delivery with the same event,
+// which goes out on the worker's next pass with its own retries.
+func handleRedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, webhookID, ok := requireGroupWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var active bool
+	err := db.QueryRow(r.Context(), "SELECT active FROM group_webhooks WHERE id::text = $1", webhookID).Scan(&active)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !active {
+		http.Error(w, "Webhook is disabled", http.StatusConflict)
+		return
+	}
+
+	deliveryID, _ := uuid.NewV7()
+	d, err := scanWebhookDelivery(db.QueryRow(r.Context(),
+		`INSERT INTO webhook_deliveries (id, webhook_id, event_id, type, payload, redelivery_of)
+		 SELECT $1, webhook_id, event_id, type, payload, id
+		 FROM webhook_deliveries
+		 WHERE id::text = $2 AND webhook_id::text = $3
+		 RETURNING `+webhookDeliveryColumns,
+		deliveryID, r.PathValue("deliveryId"), webhookID,
+	))
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]WebhookDelivery{
+		"delivery": d,
+	})
+}
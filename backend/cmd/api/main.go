@@ -8,7 +8,12 @@ import (
 	"os"
 
 	"github.com/Aayaan-Sahu/SNAPSHOT/internal/auth"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/mail"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/notifications"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/realtime"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/reminders"
 	"github.com/Aayaan-Sahu/SNAPSHOT/internal/storage"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/webhooks"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 )
@@ -35,18 +40,51 @@ func main() {
 	http.HandleFunc("/auth/login", handleLogin)
 	http.HandleFunc("/auth/callback", handleCallback)
 	http.HandleFunc("/auth/google", handleGoogleLogin)
+	http.HandleFunc("/api/email/unsubscribe", handleUnsubscribeDigest)
 
 	http.HandleFunc("/api/ping", handlePing)
 
 	// protected routes
 	http.Handle("/api/me", auth.RequireAuth(http.HandlerFunc(handleMe)))
-	http.Handle("/api/user/status", auth.RequireAuth(http.HandlerFunc(handleGetUserStatus)))
+	http.Handle("/api/me/stats", auth.RequireAuth(http.HandlerFunc(handleMyStats)))
+	http.Handle("/api/me/notification-settings", auth.RequireAuth(http.HandlerFunc(handleNotificationPreferences)))
+	http.Handle("/api/notifications", auth.RequireAuth(http.HandlerFunc(handleListNotifications)))
+	http.Handle("/api/notifications/unread-count", auth.RequireAuth(http.HandlerFunc(handleUnreadNotificationCount)))
+	http.Handle("/api/notifications/read-all", auth.RequireAuth(http.HandlerFunc(handleMarkAllNotificationsRead)))
+	http.Handle("/api/notifications/{id}/read", auth.RequireAuth(http.HandlerFunc(handleMarkNotificationRead)))
+	http.Handle("/api/devices", auth.RequireAuth(http.HandlerFunc(handleDevices)))
+	http.Handle("/api/me/discoverability", auth.RequireAuth(http.HandlerFunc(handleDiscoverability)))
+	http.Handle("/api/contacts/match", auth.RequireAuth(http.HandlerFunc(handleMatchContacts)))
+	http.Handle("/api/users/search", auth.RequireAuth(http.HandlerFunc(handleSearchUsers)))
+	http.Handle("/api/user/status", auth.RequireAuth(http.HandlerFunc(handleGetSubmissionWindow)))
 
 	http.Handle("/api/groups", auth.RequireAuth(http.HandlerFunc(handleGroups)))
 	http.Handle("/api/groups/join", auth.RequireAuth(http.HandlerFunc(handleJoinGroup)))
 	http.Handle("/api/groups/members", auth.RequireAuth(http.HandlerFunc(handleGetGroupMembers)))
 	http.Handle("/api/groups/leave", auth.RequireAuth(http.HandlerFunc(handleLeaveGroup)))
 	http.Handle("/api/groups/owner", auth.RequireAuth(http.HandlerFunc(handleGetOwner)))
+	http.Handle("/api/groups/promote", auth.RequireAuth(http.HandlerFunc(handlePromoteMember)))
+	http.Handle("/api/groups/demote", auth.RequireAuth(http.HandlerFunc(handleDemoteMember)))
+	http.Handle("/api/groups/kick", auth.RequireAuth(http.HandlerFunc(handleKickMember)))
+	http.Handle("/api/groups/transfer", auth.RequireAuth(http.HandlerFunc(handleTransferOwnership)))
+	http.Handle("/api/groups/{id}", auth.RequireAuth(http.HandlerFunc(handleGroup)))
+	http.Handle("/api/groups/{id}/avatar-upload-url", auth.RequireAuth(http.HandlerFunc(handleGetGroupAvatarUploadURL)))
+	http.Handle("/api/groups/{id}/notifications", auth.RequireAuth(http.HandlerFunc(handleGroupNotificationSettings)))
+	http.Handle("/api/groups/{id}/nudge", auth.RequireAuth(http.HandlerFunc(handleNudgeGroup)))
+	http.Handle("/api/groups/{id}/stats", auth.RequireAuth(http.HandlerFunc(handleGroupStats)))
+	http.Handle("/api/groups/{id}/invites", auth.RequireAuth(http.HandlerFunc(handleGroupInvites)))
+	http.Handle("/api/groups/{id}/invites/{inviteId}", auth.RequireAuth(http.HandlerFunc(handleRevokeGroupInvite)))
+	http.Handle("/api/groups/{id}/webhooks", auth.RequireAuth(http.HandlerFunc(handleGroupWebhooks)))
+	http.Handle("/api/groups/{id}/webhooks/{webhookId}", auth.RequireAuth(http.HandlerFunc(handleGroupWebhook)))
+	http.Handle("/api/groups/{id}/webhooks/{webhookId}/deliveries", auth.RequireAuth(http.HandlerFunc(handleListWebhookDeliveries)))
+	http.Handle("/api/groups/{id}/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", auth.RequireAuth(http.HandlerFunc(handleRedeliverWebhook)))
+	http.Handle("/api/groups/invitations", auth.RequireAuth(http.HandlerFunc(handleListMyInvitations)))
+	http.Handle("/api/groups/join-requests", auth.RequireAuth(http.HandlerFunc(handleRequestToJoinGroup)))
+	http.Handle("/api/groups/join-requests/approve", auth.RequireAuth(http.HandlerFunc(handleApproveJoinRequest)))
+	http.Handle("/api/groups/join-requests/deny", auth.RequireAuth(http.HandlerFunc(handleDenyJoinRequest)))
+	http.Handle("/api/groups/join-requests/cancel", auth.RequireAuth(http.HandlerFunc(handleCancelJoinRequest)))
+	http.Handle("/api/groups/join-requests/incoming", auth.RequireAuth(http.HandlerFunc(handleListIncomingJoinRequests)))
+	http.Handle("/api/groups/join-requests/outgoing", auth.RequireAuth(http.HandlerFunc(handleListOutgoingJoinRequests)))
 
 	http.Handle("/api/friends", auth.RequireAuth(http.HandlerFunc(handleListFriends)))
 	http.Handle("/api/friends/request", auth.RequireAuth(http.HandlerFunc(handleFriendRequest)))
@@ -56,6 +94,20 @@ func main() {
 	http.Handle("/api/friends/requests/incoming", auth.RequireAuth(http.HandlerFunc(handleListIncomingFriendRequests)))
 	http.Handle("/api/friends/requests/outgoing", auth.RequireAuth(http.HandlerFunc(handleListOutgoingFriendRequests)))
 	http.Handle("/api/friends/remove", auth.RequireAuth(http.HandlerFunc(handleRemoveFriend)))
+	http.Handle("/api/friends/close", auth.RequireAuth(http.HandlerFunc(handleCloseFriends)))
+	http.Handle("/api/friends/suggestions", auth.RequireAuth(http.HandlerFunc(handleFriendSuggestions)))
+	http.Handle("/api/friends/suggestions/dismiss", auth.RequireAuth(http.HandlerFunc(handleDismissSuggestion)))
+	http.Handle("/api/friends/lists", auth.RequireAuth(http.HandlerFunc(handleFriendLists)))
+	http.Handle("/api/friends/lists/{id}", auth.RequireAuth(http.HandlerFunc(handleFriendList)))
+	http.Handle("/api/friends/lists/{id}/members", auth.RequireAuth(http.HandlerFunc(handleAddFriendListMember)))
+	http.Handle("/api/friends/lists/{id}/members/{userId}", auth.RequireAuth(http.HandlerFunc(handleRemoveFriendListMember)))
+	http.Handle("/api/friends/code", auth.RequireAuth(http.HandlerFunc(handleFriendCode)))
+	http.Handle("/api/friends/code/rotate", auth.RequireAuth(http.HandlerFunc(handleRotateFriendCode)))
+	http.Handle("/api/friends/code/qr", auth.RequireAuth(http.HandlerFunc(handleGetFriendCodeQR)))
+	http.Handle("/api/friends/redeem", auth.RequireAuth(http.HandlerFunc(handleRedeemFriendCode)))
+	http.Handle("/api/friends/block", auth.RequireAuth(http.HandlerFunc(handleBlockUser)))
+	http.Handle("/api/friends/unblock", auth.RequireAuth(http.HandlerFunc(handleUnblockUser)))
+	http.Handle("/api/friends/blocked", auth.RequireAuth(http.HandlerFunc(handleListBlockedUsers)))
 
 	s3Client, err = storage.NewS3Service()
 	if err != nil {
@@ -67,6 +119,28 @@ func main() {
 	http.Handle("/api/photos/upload-url", auth.RequireAuth(http.HandlerFunc(handleGetUploadURL)))
 	http.Handle("/api/photos", auth.RequireAuth(http.HandlerFunc(handleConfirmPhoto)))
 	http.Handle("/api/photos/slideshow", auth.RequireAuth(http.HandlerFunc(handleGetSlideshow)))
+	http.Handle("/api/photos/window", auth.RequireAuth(http.HandlerFunc(handleGetSubmissionWindow)))
+	http.Handle("/api/photos/retake", auth.RequireAuth(http.HandlerFunc(handleRetakePhoto)))
+	http.Handle("/api/photos/{id}", auth.RequireAuth(http.HandlerFunc(handlePhoto)))
+	http.Handle("/api/photos/{id}/reactions", auth.RequireAuth(http.HandlerFunc(handlePhotoReactions)))
+	http.Handle("/api/photos/{id}/comments", auth.RequireAuth(http.HandlerFunc(handlePhotoComments)))
+	http.Handle("/api/comments/{id}", auth.RequireAuth(http.HandlerFunc(handleComment)))
+	http.Handle("/api/events/stream", auth.RequireAuth(http.HandlerFunc(handleEventStream)))
+
+	pushService := notifications.NewService(db, notifications.NewExpoClientFromEnv())
+	dispatcher = notifications.NewDispatcher(db, pushService)
+	go pushService.RunReceiptPoller(context.Background())
+	go reminders.NewScheduler(db, dispatcher).Run(context.Background())
+	go runFriendRequestSweeper(context.Background())
+
+	feedHub = realtime.NewHub(db)
+	go feedHub.Run(context.Background())
+	go runFeedEventPruner(context.Background())
+	if len(unsubscribeSecret()) == 0 {
+		log.Fatal("UNSUBSCRIBE_SECRET or JWT_SECRET must be set to sign unsubscribe links")
+	}
+	go runDigestScheduler(context.Background(), mail.NewTransportFromEnv())
+	go webhooks.NewWorker(db, webhooks.NewClient()).Run(context.Background())
 
 	fmt.Println("Server running on :8080")
 	if err := http.ListenAndServe(":8080", nil); err != nil {
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/notifications"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const notifyTimeout = 30 * time.Second
+
+var dispatcher *notifications.Dispatcher
+
+// inboxTypes are also kept in the in-app inbox so they survive a missed push.
+var inboxTypes = map[string]bool{
+	notifications.TypeFriendRequest: true,
+	notifications.TypeFriendAccept:  true,
+	notifications.TypeGroupAdded:    true,
+	notifications.TypeGroupInvite:   true,
+	notifications.TypeComment:       true,
+	notifications.TypeReaction:      true,
+}
+
+// nullableID turns an empty ID into NULL for optional reference columns.
+func nullableID(id string) *string {
+	if id == "" {
+		return nil
+	}
+	return &id
+}
+
+// recordInbox stores n for each user. Preferences only govern pushes, so every
+// recipient gets an entry.
+func recordInbox(ctx context.Context, userIDs []string, n notifications.Notification) error {
+	batch := &pgx.Batch{}
+	for _, userID := range userIDs {
+		id, _ := uuid.NewV7()
+		batch.Queue(
+			`INSERT INTO notifications (id, user_id, type, title, body, actor_id, group_id, photo_id)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			id, userID, n.Type, n.Title, n.Body, nullableID(n.ActorID), nullableID(n.GroupID), nullableID(n.PhotoID),
+		)
+	}
+	return db.SendBatch(ctx, batch).Close()
+}
+
+// notifyUsers records inbox entries right away and delivers pushes in the background
+// so handlers never wait on the push service.
+func notifyUsers(ctx context.Context, userIDs []string, n notifications.Notification) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	if inboxTypes[n.Type] {
+		if err := recordInbox(ctx, userIDs, n); err != nil {
+			log.Printf("Failed to record %s notification: %v", n.Type, err)
+		}
+	}
+
+	if dispatcher == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := dispatcher.Dispatch(ctx, userIDs, n); err != nil {
+			log.Printf("Failed to send %s notification: %v", n.Type, err)
+		}
+	}()
+}
+
+// displayName is how a user is referred to in notifications.
+func displayName(ctx context.Context, userID string) string {
+	var name string
+	err := db.QueryRow(ctx,
+		"SELECT COALESCE(NULLIF(name, ''), handle, 'Someone') FROM users WHERE id::text = $1",
+		userID,
+	).Scan(&name)
+	if err != nil {
+		return "Someone"
+	}
+	return name
+}
+
+func groupName(ctx context.Context, groupID string) string {
+	var name string
+	if err := db.QueryRow(ctx, "SELECT name FROM groups WHERE id::text = $1", groupID).Scan(&name); err != nil {
+		return "a group"
+	}
+	return name
+}
+
+func notifyFriendRequest(ctx context.Context, requesterID, targetID string) {
+	notifyUsers(ctx, []string{targetID}, notifications.Notification{
+		Type:    notifications.TypeFriendRequest,
+		Title:   "New friend request",
+		Body:    fmt.Sprintf("%s wants to be friends", displayName(ctx, requesterID)),
+		Data:    map[string]any{"user_id": requesterID},
+		ActorID: requesterID,
+	})
+}
+
+func notifyFriendAccepted(ctx context.Context, accepterID, requesterID string) {
+	notifyUsers(ctx, []string{requesterID}, notifications.Notification{
+		Type:    notifications.TypeFriendAccept,
+		Title:   "Friend request accepted",
+		Body:    fmt.Sprintf("%s accepted your friend request", displayName(ctx, accepterID)),
+		Data:    map[string]any{"user_id": accepterID},
+		ActorID: accepterID,
+	})
+}
+
+// notifyGroupAdded tells users they are now members. actorID is whoever added them.
+func notifyGroupAdded(ctx context.Context, actorID, groupID string, userIDs []string) {
+	notifyUsers(ctx, userIDs, notifications.Notification{
+		Type:    notifications.TypeGroupAdded,
+		Title:   groupName(ctx, groupID),
+		Body:    fmt.Sprintf("%s added you to the group", displayName(ctx, actorID)),
+		Data:    map[string]any{"group_id": groupID},
+		GroupID: groupID,
+		ActorID: actorID,
+	})
+}
+
+func notifyGroupInvite(ctx context.Context, inviterID, groupID string, userIDs []string) {
+	notifyUsers(ctx, userIDs, notifications.Notification{
+		Type:    notifications.TypeGroupInvite,
+		Title:   groupName(ctx, groupID),
+		Body:    fmt.Sprintf("%s invited you to join", displayName(ctx, inviterID)),
+		Data:    map[string]any{"group_id": groupID},
+		GroupID: groupID,
+		ActorID: inviterID,
+	})
+}
+
+// notifyNewPhoto tells everyone who can see the photo in at least one shared group.
+// Each viewer is notified through one group, preferring one they haven't muted.
+func notifyNewPhoto(ctx context.Context, ownerID, photoID string) {
+	rows, err := db.Query(ctx,
+		`SELECT DISTINCT ON (viewer_gm.user_id) viewer_gm.user_id::text, owner_gm.group_id::text
+		 FROM photos p
+		 JOIN group_members owner_gm ON owner_gm.user_id = p.user_id
+		 JOIN group_members viewer_gm ON viewer_gm.group_id = owner_gm.group_id
+		 LEFT JOIN group_notification_settings gns
+			ON gns.user_id = viewer_gm.user_id AND gns.group_id = owner_gm.group_id
+		 WHERE p.id = $1
+		 AND viewer_gm.user_id <> p.user_id
+		 AND `+photoVisibleSQL("p", "owner_gm.group_id", "viewer_gm.user_id")+`
+		 ORDER BY viewer_gm.user_id, COALESCE(gns.muted, FALSE)`,
+		photoID,
+	)
+	if err != nil {
+		log.Printf("Failed to find viewers for photo %s: %v", photoID, err)
+		return
+	}
+	defer rows.Close()
+
+	viewersByGroup := make(map[string][]string)
+	for rows.Next() {
+		var viewerID, groupID string
+		if err := rows.Scan(&viewerID, &groupID); err != nil {
+			continue
+		}
+		viewersByGroup[groupID] = append(viewersByGroup[groupID], viewerID)
+	}
+
+	body := fmt.Sprintf("%s just posted", displayName(ctx, ownerID))
+	for groupID, viewers := range viewersByGroup {
+		notifyUsers(ctx, viewers, notifications.Notification{
+			Type:    notifications.TypeNewPhoto,
+			Title:   "New snapshot",
+			Body:    body,
+			Data:    map[string]any{"photo_id": photoID, "user_id": ownerID, "group_id": groupID},
+			GroupID: groupID,
+			ActorID: ownerID,
+			PhotoID: photoID,
+		})
+	}
+}
+
+// notifyReaction tells the photo's owner, unless they reacted to their own photo.
+func notifyReaction(ctx context.Context, reactorID, photoID, emoji string) {
+	var ownerID string
+	if err := db.QueryRow(ctx, "SELECT user_id::text FROM photos WHERE id = $1", photoID).Scan(&ownerID); err != nil || ownerID == reactorID {
+		return
+	}
+
+	notifyUsers(ctx, []string{ownerID}, notifications.Notification{
+		Type:    notifications.TypeReaction,
+		Title:   "New reaction",
+		Body:    fmt.Sprintf("%s reacted %s to your snapshot", displayName(ctx, reactorID), emoji),
+		Data:    map[string]any{"photo_id": photoID, "user_id": reactorID},
+		ActorID: reactorID,
+		PhotoID: photoID,
+	})
+}
+
+// notifyComment tells the photo's owner and, for replies, the parent comment's author.
+func notifyComment(ctx context.Context, commenterID, photoID, commentID string, parentID *string) {
+	var ownerID string
+	var parentAuthorID *string
+	err := db.QueryRow(ctx,
+		`SELECT p.user_id::text, (SELECT c.user_id::text FROM photo_comments c WHERE c.id::text = $2)
+		 FROM photos p WHERE p.id = $1`,
+		photoID, parentID,
+	).Scan(&ownerID, &parentAuthorID)
+	if err != nil {
+		return
+	}
+
+	recipients := make([]string, 0, 2)
+	if ownerID != commenterID {
+		recipients = append(recipients, ownerID)
+	}
+	if parentAuthorID != nil && *parentAuthorID != commenterID && *parentAuthorID != ownerID {
+		recipients = append(recipients, *parentAuthorID)
+	}
+
+	notifyUsers(ctx, recipients, notifications.Notification{
+		Type:    notifications.TypeComment,
+		Title:   "New comment",
+		Body:    fmt.Sprintf("%s commented on a snapshot", displayName(ctx, commenterID)),
+		Data:    map[string]any{"photo_id": photoID, "comment_id": commentID, "user_id": commenterID},
+		ActorID: commenterID,
+		PhotoID: photoID,
+	})
+}
+
+func nudgeNotification(ctx context.Context, senderID, groupID string, slot time.Time) notifications.Notification {
+	return notifications.Notification{
+		Type:    notifications.TypeNudge,
+		Title:   groupName(ctx, groupID),
+		Body:    fmt.Sprintf("%s is waiting on your snapshot", displayName(ctx, senderID)),
+		Data:    map[string]any{"group_id": groupID, "user_id": senderID, "slot": slot.Format(time.RFC3339)},
+		GroupID: groupID,
+		ActorID: senderID,
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	AudienceAll    = "all"
+	AudienceGroups = "groups"
+	AudienceLists  = "lists"
+
+	// AudienceCloseFriends is kept for clients that predate friend lists. It stands
+	// for the built-in close friends list and is stored as that list.
+	AudienceCloseFriends = "close_friends"
+)
+
+var (
+	errInvalidAudience      = errors.New("audience must be all, groups, lists or close_friends")
+	errAudienceGroupsNeeded = errors.New("group_ids is required when audience is groups")
+	errAudienceNotMember    = errors.New("you can only share with groups you belong to")
+	errAudienceListsNeeded  = errors.New("list_ids is required when audience is lists")
+	errAudienceNotListOwner = errors.New("you can only share with your own friend lists")
+)
+
+// uniqueIDs drops empty and repeated IDs, keeping the first occurrence.
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+// normalizeAudience validates the shape of an audience selection, defaulting to everyone.
+// It returns the group IDs or list IDs the audience targets, whichever applies.
+func normalizeAudience(audience string, groupIDs, listIDs []string) (string, []string, error) {
+	if audience == "" {
+		audience = AudienceAll
+	}
+
+	switch audience {
+	case AudienceAll, AudienceCloseFriends:
+		return audience, nil, nil
+	case AudienceGroups:
+		unique := uniqueIDs(groupIDs)
+		if len(unique) == 0 {
+			return "", nil, errAudienceGroupsNeeded
+		}
+		return audience, unique, nil
+	case AudienceLists:
+		unique := uniqueIDs(listIDs)
+		if len(unique) == 0 {
+			return "", nil, errAudienceListsNeeded
+		}
+		return audience, unique, nil
+	default:
+		return "", nil, errInvalidAudience
+	}
+}
+
+// resolveCloseFriendsAudience turns the close_friends alias into the built-in list it
+// stands for, creating the list if the user doesn't have one yet. Other audiences pass
+// through unchanged.
+func resolveCloseFriendsAudience(ctx context.Context, tx pgx.Tx, userID, audience string, targetIDs []string) (string, []string, error) {
+	if audience != AudienceCloseFriends {
+		return audience, targetIDs, nil
+	}
+	listID, err := ensureCloseFriendsList(ctx, tx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	return AudienceLists, []string{listID}, nil
+}
+
+// setPhotoAudience replaces a photo's audience. Callers must have normalized the input;
+// targetIDs are group IDs or friend list IDs depending on the audience.
+func setPhotoAudience(ctx context.Context, tx pgx.Tx, userID, photoID, audience string, targetIDs []string) error {
+	if audience == AudienceLists {
+		var owned int
+		err := tx.QueryRow(ctx,
+			`SELECT COUNT(*) FROM friend_lists
+			 WHERE user_id = $1 AND id::text = ANY($2)`,
+			userID, targetIDs,
+		).Scan(&owned)
+		if err != nil {
+			return err
+		}
+		if owned != len(targetIDs) {
+			return errAudienceNotListOwner
+		}
+	}
+
+	if audience == AudienceGroups {
+		var memberOf int
+		err := tx.QueryRow(ctx,
+			`SELECT COUNT(*) FROM group_members
+			 WHERE user_id = $1 AND group_id::text = ANY($2)`,
+			userID, targetIDs,
+		).Scan(&memberOf)
+		if err != nil {
+			return err
+		}
+		if memberOf != len(targetIDs) {
+			return errAudienceNotMember
+		}
+	}
+
+	_, err := tx.Exec(ctx,
+		`INSERT INTO photo_audiences (photo_id, audience) VALUES ($1, $2)
+		 ON CONFLICT (photo_id) DO UPDATE SET audience = EXCLUDED.audience, updated_at = NOW()`,
+		photoID, audience,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM photo_audience_groups WHERE photo_id = $1", photoID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "DELETE FROM photo_audience_lists WHERE photo_id = $1", photoID)
+	if err != nil {
+		return err
+	}
+
+	insert := "INSERT INTO photo_audience_groups (photo_id, group_id) VALUES ($1, $2)"
+	if audience == AudienceLists {
+		insert = "INSERT INTO photo_audience_lists (photo_id, list_id) VALUES ($1, $2)"
+	}
+	for _, targetID := range targetIDs {
+		if _, err := tx.Exec(ctx, insert, photoID, targetID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isAudienceError(err error) bool {
+	return errors.Is(err, errInvalidAudience) ||
+		errors.Is(err, errAudienceGroupsNeeded) ||
+		errors.Is(err, errAudienceNotMember) ||
+		errors.Is(err, errAudienceListsNeeded) ||
+		errors.Is(err, errAudienceNotListOwner)
+}
+
+// photoVisibleSQL returns a predicate restricting the photo aliased as photo to those
+// the viewer may see when browsing the given group. Arguments are SQL expressions.
+// Photos from users blocked by or blocking the viewer are never visible.
+func photoVisibleSQL(photo, groupID, viewerID string) string {
+	return fmt.Sprintf(`(%[1]s.user_id = %[3]s OR (%[4]s AND EXISTS (
+		SELECT 1 FROM photo_audiences pa
+		WHERE pa.photo_id = %[1]s.id
+		AND (
+			pa.audience = 'all'
+			OR (pa.audience = 'groups' AND EXISTS (
+				SELECT 1 FROM photo_audience_groups pag
+				WHERE pag.photo_id = %[1]s.id AND pag.group_id = %[2]s
+			))
+			OR (pa.audience = 'lists' AND EXISTS (
+				SELECT 1 FROM photo_audience_lists pal
+				JOIN friend_list_members flm ON flm.list_id = pal.list_id
+				WHERE pal.photo_id = %[1]s.id AND flm.friend_id = %[3]s
+			))
+		)
+	)))`, photo, groupID, viewerID, notBlockedSQL(photo+".user_id", viewerID))
+}
+
+// viewablePhotoSQL returns a predicate that holds when the viewer shares a group with
+// the owner of the photo aliased as photo in which it is visible. Owners can always
+// see their own photos.
+func viewablePhotoSQL(photo, viewerID string) string {
+	return fmt.Sprintf(`(%[1]s.user_id = %[2]s OR EXISTS (
+		SELECT 1
+		FROM group_members owner_gm
+		JOIN group_members viewer_gm ON viewer_gm.group_id = owner_gm.group_id
+		WHERE owner_gm.user_id = %[1]s.user_id
+		AND viewer_gm.user_id = %[2]s
+		AND %[3]s
+	))`, photo, viewerID, photoVisibleSQL(photo, "owner_gm.group_id", viewerID))
+}
+
+// canViewPhoto reports whether the viewer can see the photo in any group they share
+// with its owner.
+func canViewPhoto(ctx context.Context, viewerID, photoID string) (bool, error) {
+	var visible bool
+	err := db.QueryRow(ctx,
+		`SELECT EXISTS(
+			SELECT 1 FROM photos p
+			WHERE p.id = $1
+			AND `+viewablePhotoSQL("p", "$2")+`
+		)`,
+		photoID, viewerID,
+	).Scan(&visible)
+	return visible, err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/webhooks"
+)
+
+// queueWebhookEvent adds an event about userID to the group's webhook outbox. It must
+// run in the transaction making the change so the event exists exactly when the
+// change does. Groups without an active webhook are skipped.
+func queueWebhookEvent(ctx context.Context, q dbtx, groupID, eventType, userID string, data map[string]any) error {
+	if data == nil {
+		data = map[string]any{}
+	}
+	_, err := q.Exec(ctx,
+		`INSERT INTO webhook_outbox (group_id, type, data)
+		 SELECT $1::uuid, $2::text,
+			$4::jsonb || jsonb_build_object('user_id', u.id::text, 'user_name', COALESCE(NULLIF(u.name, ''), u.handle, ''))
+		 FROM users u
+		 WHERE u.id::text = $3
+		 AND EXISTS (SELECT 1 FROM group_webhooks wh WHERE wh.group_id = $1::uuid AND wh.active)`,
+		groupID, eventType, userID, data,
+	)
+	return err
+}
+
+// queuePhotoWebhookEvents queues a confirmed photo for each of the owner's groups it
+// is shared with as a whole. Photos limited to friend lists, close friends included,
+// are never sent to webhooks.
+func queuePhotoWebhookEvents(ctx context.Context, q dbtx, userID, photoID string, slot time.Time) error {
+	_, err := q.Exec(ctx,
+		`INSERT INTO webhook_outbox (group_id, type, data)
+		 SELECT gm.group_id, $3::text, jsonb_build_object(
+			'photo_id', $2::text,
+			'slot', $4::timestamptz,
+			'user_id', u.id::text,
+			'user_name', COALESCE(NULLIF(u.name, ''), u.handle, '')
+		 )
+		 FROM group_members gm
+		 JOIN users u ON u.id = gm.user_id
+		 JOIN photo_audiences pa ON pa.photo_id::text = $2
+		 WHERE gm.user_id::text = $1
+		 AND (pa.audience = 'all' OR (pa.audience = 'groups' AND EXISTS (
+			SELECT 1 FROM photo_audience_groups pag
+			WHERE pag.photo_id = pa.photo_id AND pag.group_id = gm.group_id
+		 )))
+		 AND EXISTS (SELECT 1 FROM group_webhooks wh WHERE wh.group_id = gm.group_id AND wh.active)`,
+		userID, photoID, webhooks.EventPhotoConfirmed, slot,
+	)
+	return err
+}
@@ -0,0 +1,131 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is one email with a plain text and an HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	// Headers are added as is, e.g. List-Unsubscribe
+	Headers map[string]string
+}
+
+// Transport delivers messages. Implementations must be safe for concurrent use.
+type Transport interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ErrDisabled is what DisabledTransport returns for every message.
+var ErrDisabled = errors.New("mail is disabled, set MAIL_TRANSPORT to send it")
+
+// NewTransportFromEnv picks the transport from MAIL_TRANSPORT: smtp, file (writes
+// to MAIL_DIR) or memory. Anything else, including unset, disables mail so
+// development never sends real email.
+func NewTransportFromEnv() Transport {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "SNAPSHOT <no-reply@snapshot.local>"
+	}
+
+	switch os.Getenv("MAIL_TRANSPORT") {
+	case "smtp":
+		return NewSMTPTransport(
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			from,
+		)
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return NewFileTransport(dir, from)
+	case "memory":
+		return NewMemoryTransport()
+	default:
+		log.Println("MAIL_TRANSPORT is not set to smtp, file or memory, email is disabled")
+		return DisabledTransport{}
+	}
+}
+
+// encode renders msg as a multipart/alternative MIME message.
+func encode(from string, msg Message) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	for _, alt := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alt.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(alt.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"From":         from,
+		"To":           msg.To,
+		"Subject":      mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"Message-ID":   fmt.Sprintf("<%s@snapshot>", uuid.NewString()),
+		"MIME-Version": "1.0",
+		"Content-Type": "multipart/alternative; boundary=" + parts.Boundary(),
+	}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	for k, v := range headers {
+		if strings.ContainsAny(k+v, "\r\n") {
+			return nil, fmt.Errorf("header %s contains a line break", k)
+		}
+	}
+
+	// sorted so the output is stable
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&out, "%s: %s\r\n", k, headers[k])
+	}
+	out.WriteString("\r\n")
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
@@ -0,0 +1,129 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SMTPTransport sends through an SMTP relay, using STARTTLS when the server offers it.
+type SMTPTransport struct {
+	addr string
+	auth smtp.Auth
+	from string
+	// envelopeFrom is the bare address from From, used for MAIL FROM
+	envelopeFrom string
+}
+
+// NewSMTPTransport authenticates with PLAIN when a username is given. port defaults to 587.
+func NewSMTPTransport(host, port, username, password, from string) *SMTPTransport {
+	if port == "" {
+		port = "587"
+	}
+
+	t := &SMTPTransport{
+		addr:         net.JoinHostPort(host, port),
+		from:         from,
+		envelopeFrom: from,
+	}
+	if addr, err := mail.ParseAddress(from); err == nil {
+		t.envelopeFrom = addr.Address
+	}
+	if username != "" {
+		t.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return t
+}
+
+func (t *SMTPTransport) Send(ctx context.Context, msg Message) error {
+	data, err := encode(t.from, msg)
+	if err != nil {
+		return err
+	}
+
+	// net/smtp has no context support, so the deadline is only checked up front
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := smtp.SendMail(t.addr, t.auth, t.envelopeFrom, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("smtp send to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// FileTransport writes each message to its own .eml file in a directory, for
+// looking at rendered mail locally.
+type FileTransport struct {
+	dir  string
+	from string
+}
+
+func NewFileTransport(dir, from string) *FileTransport {
+	return &FileTransport{dir: dir, from: from}
+}
+
+func (t *FileTransport) Send(ctx context.Context, msg Message) error {
+	data, err := encode(t.from, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), sanitizeFilename(msg.To))
+	return os.WriteFile(filepath.Join(t.dir, name), data, 0o644)
+}
+
+func sanitizeFilename(s string) string {
+	out := []rune(s)
+	for i, r := range out {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '@') {
+			out[i] = '_'
+		}
+	}
+	return string(out)
+}
+
+// DisabledTransport refuses every message, so callers can tell nothing went out.
+type DisabledTransport struct{}
+
+func (DisabledTransport) Send(ctx context.Context, msg Message) error {
+	return ErrDisabled
+}
+
+// memoryTransportLimit bounds what a MemoryTransport holds, dropping the oldest first.
+const memoryTransportLimit = 100
+
+// MemoryTransport keeps the most recent sent messages so they can be inspected.
+type MemoryTransport struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{}
+}
+
+func (t *MemoryTransport) Send(ctx context.Context, msg Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.messages) >= memoryTransportLimit {
+		t.messages = append(t.messages[:0], t.messages[len(t.messages)-memoryTransportLimit+1:]...)
+	}
+	t.messages = append(t.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the messages still held, oldest first.
+func (t *MemoryTransport) Messages() []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Message(nil), t.messages...)
+}
@@ -0,0 +1,118 @@
+package notifications
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// categoryColumn maps each notification type to the preference that controls it.
+var categoryColumn = map[string]string{
+	TypeFriendRequest:   "friend_requests",
+	TypeFriendAccept:    "friend_requests",
+	TypeGroupAdded:      "group_invites",
+	TypeGroupInvite:     "group_invites",
+	TypeNewPhoto:        "new_photos",
+	TypeCaptureReminder: "reminders",
+	TypeLastChance:      "reminders",
+	TypeComment:         "comments",
+	TypeReaction:        "reactions",
+	TypeNudge:           "nudges",
+}
+
+// Dispatcher is the one way notifications leave the server. It drops recipients who
+// turned the category off, muted the group or are inside their quiet hours.
+type Dispatcher struct {
+	db   *pgxpool.Pool
+	push *Service
+}
+
+func NewDispatcher(db *pgxpool.Pool, push *Service) *Dispatcher {
+	return &Dispatcher{db: db, push: push}
+}
+
+// InQuietHours reports whether the local clock time of now falls in [start, end),
+// wrapping past midnight when start is after end. Equal bounds mean no quiet hours.
+func InQuietHours(now time.Time, start, end time.Duration) bool {
+	t := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+	switch {
+	case start == end:
+		return false
+	case start < end:
+		return t >= start && t < end
+	default:
+		return t >= start || t < end
+	}
+}
+
+// quiet reports whether a user with the given timezone and quiet hours, in seconds
+// after local midnight, is inside them at now. Unknown timezones are read as UTC.
+func quiet(now time.Time, timezone string, start, end *int64) bool {
+	if start == nil || end == nil {
+		return false
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return InQuietHours(now.In(loc), time.Duration(*start)*time.Second, time.Duration(*end)*time.Second)
+}
+
+// Recipients filters userIDs down to those who want n right now.
+func (d *Dispatcher) Recipients(ctx context.Context, userIDs []string, n Notification) ([]string, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	column, ok := categoryColumn[n.Type]
+	enabled := "TRUE"
+	if ok {
+		enabled = "COALESCE(np." + column + ", TRUE)"
+	}
+
+	// quiet hours come back as seconds after local midnight
+	rows, err := d.db.Query(ctx,
+		`SELECT u.id::text, COALESCE(u.timezone, 'UTC'),
+			EXTRACT(EPOCH FROM np.quiet_hours_start)::bigint,
+			EXTRACT(EPOCH FROM np.quiet_hours_end)::bigint
+		 FROM users u
+		 LEFT JOIN notification_preferences np ON np.user_id = u.id
+		 WHERE u.id::text = ANY($1)
+		 AND `+enabled+`
+		 AND ($2 = '' OR NOT EXISTS (
+			SELECT 1 FROM group_notification_settings gns
+			WHERE gns.user_id = u.id AND gns.group_id::text = $2 AND gns.muted
+		 ))`,
+		userIDs, n.GroupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	recipients := make([]string, 0, len(userIDs))
+	for rows.Next() {
+		var id, timezone string
+		var quietStart, quietEnd *int64
+		if err := rows.Scan(&id, &timezone, &quietStart, &quietEnd); err != nil {
+			return nil, err
+		}
+
+		if quiet(now, timezone, quietStart, quietEnd) {
+			continue
+		}
+		recipients = append(recipients, id)
+	}
+	return recipients, rows.Err()
+}
+
+// Dispatch sends n to every user in userIDs whose settings allow it.
+func (d *Dispatcher) Dispatch(ctx context.Context, userIDs []string, n Notification) error {
+	recipients, err := d.Recipients(ctx, userIDs, n)
+	if err != nil {
+		return err
+	}
+	return d.push.Send(ctx, recipients, n)
+}
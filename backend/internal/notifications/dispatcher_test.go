@@ -0,0 +1,81 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInQuietHours(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 19, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		start, end time.Duration
+		want       bool
+	}{
+		{"same day inside", at(13, 30), 13 * time.Hour, 15 * time.Hour, true},
+		{"same day at start", at(13, 0), 13 * time.Hour, 15 * time.Hour, true},
+		{"same day at end", at(15, 0), 13 * time.Hour, 15 * time.Hour, false},
+		{"same day before", at(12, 59), 13 * time.Hour, 15 * time.Hour, false},
+		{"overnight late", at(23, 15), 22 * time.Hour, 7 * time.Hour, true},
+		{"overnight early", at(6, 59), 22 * time.Hour, 7 * time.Hour, true},
+		{"overnight daytime", at(12, 0), 22 * time.Hour, 7 * time.Hour, false},
+		{"overnight at end", at(7, 0), 22 * time.Hour, 7 * time.Hour, false},
+		{"equal bounds", at(9, 0), 9 * time.Hour, 9 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InQuietHours(tt.now, tt.start, tt.end); got != tt.want {
+				t.Errorf("InQuietHours(%s, %v, %v) = %v, want %v", tt.now.Format("15:04"), tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	seconds := func(d time.Duration) *int64 {
+		s := int64(d.Seconds())
+		return &s
+	}
+	// 06:30 UTC is 23:30 the night before in Los Angeles
+	now := time.Date(2026, 10, 19, 6, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		timezone   string
+		start, end *int64
+		want       bool
+	}{
+		{"no quiet hours", "America/Los_Angeles", nil, nil, false},
+		{"only a start", "America/Los_Angeles", seconds(22 * time.Hour), nil, false},
+		{"local night", "America/Los_Angeles", seconds(22 * time.Hour), seconds(7 * time.Hour), true},
+		{"local morning elsewhere", "Asia/Tokyo", seconds(22 * time.Hour), seconds(7 * time.Hour), false},
+		{"unknown timezone is utc", "Mars/Olympus", seconds(6 * time.Hour), seconds(7 * time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quiet(now, tt.timezone, tt.start, tt.end); got != tt.want {
+				t.Errorf("quiet = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNudgesFollowTheirPreference(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want string
+	}{
+		{TypeNudge, "nudges"},
+		{TypeCaptureReminder, "reminders"},
+		{TypeLastChance, "reminders"},
+	}
+	for _, tt := range tests {
+		if got := categoryColumn[tt.typ]; got != tt.want {
+			t.Errorf("%s is controlled by %q, want %q", tt.typ, got, tt.want)
+		}
+	}
+}
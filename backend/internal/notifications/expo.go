@@ -0,0 +1,136 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultExpoBaseURL = "https://exp.host/--/api/v2/push"
+	// Expo accepts at most 100 messages per send and 1000 ids per receipt lookup
+	maxMessagesPerSend = 100
+	maxIDsPerReceipts  = 1000
+)
+
+// ErrorDeviceNotRegistered is reported by Expo when a token can no longer receive pushes.
+const ErrorDeviceNotRegistered = "DeviceNotRegistered"
+
+type Message struct {
+	To    string         `json:"to"`
+	Title string         `json:"title,omitempty"`
+	Body  string         `json:"body,omitempty"`
+	Data  map[string]any `json:"data,omitempty"`
+	Sound string         `json:"sound,omitempty"`
+}
+
+type Details struct {
+	Error string `json:"error,omitempty"`
+}
+
+// Ticket is Expo's immediate answer to one message, in the order the messages were sent.
+type Ticket struct {
+	Status  string  `json:"status"` // ok or error
+	ID      string  `json:"id"`
+	Message string  `json:"message"`
+	Details Details `json:"details"`
+}
+
+// Receipt is the final delivery result for a ticket, available some time after sending.
+type Receipt struct {
+	Status  string  `json:"status"`
+	Message string  `json:"message"`
+	Details Details `json:"details"`
+}
+
+// ExpoClient talks to the Expo push API. BaseURL can point at a local stub.
+type ExpoClient struct {
+	BaseURL     string
+	AccessToken string
+	HTTP        *http.Client
+}
+
+// NewExpoClientFromEnv reads EXPO_PUSH_URL and EXPO_ACCESS_TOKEN.
+func NewExpoClientFromEnv() *ExpoClient {
+	baseURL := os.Getenv("EXPO_PUSH_URL")
+	if baseURL == "" {
+		baseURL = defaultExpoBaseURL
+	}
+	return &ExpoClient{
+		BaseURL:     strings.TrimSuffix(baseURL, "/"),
+		AccessToken: os.Getenv("EXPO_ACCESS_TOKEN"),
+		HTTP:        &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (c *ExpoClient) post(ctx context.Context, path string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("expo %s: unexpected status %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Send delivers messages in batches and returns one ticket per message.
+func (c *ExpoClient) Send(ctx context.Context, messages []Message) ([]Ticket, error) {
+	tickets := make([]Ticket, 0, len(messages))
+	for start := 0; start < len(messages); start += maxMessagesPerSend {
+		batch := messages[start:min(start+maxMessagesPerSend, len(messages))]
+
+		var resp struct {
+			Data []Ticket `json:"data"`
+		}
+		if err := c.post(ctx, "/send", batch, &resp); err != nil {
+			return tickets, err
+		}
+		if len(resp.Data) != len(batch) {
+			return tickets, fmt.Errorf("expo send: got %d tickets for %d messages", len(resp.Data), len(batch))
+		}
+		tickets = append(tickets, resp.Data...)
+	}
+	return tickets, nil
+}
+
+// GetReceipts looks up receipts by ticket id. Ids without a receipt yet are omitted.
+func (c *ExpoClient) GetReceipts(ctx context.Context, ids []string) (map[string]Receipt, error) {
+	receipts := make(map[string]Receipt, len(ids))
+	for start := 0; start < len(ids); start += maxIDsPerReceipts {
+		batch := ids[start:min(start+maxIDsPerReceipts, len(ids))]
+
+		var resp struct {
+			Data map[string]Receipt `json:"data"`
+		}
+		if err := c.post(ctx, "/getReceipts", map[string][]string{"ids": batch}, &resp); err != nil {
+			return receipts, err
+		}
+		for id, receipt := range resp.Data {
+			receipts[id] = receipt
+		}
+	}
+	return receipts, nil
+}
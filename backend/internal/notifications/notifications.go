@@ -0,0 +1,198 @@
+package notifications
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	TypeFriendRequest = "friend_request"
+	TypeFriendAccept  = "friend_accept"
+	TypeGroupAdded    = "group_added"
+	TypeGroupInvite   = "group_invite"
+	TypeNewPhoto      = "new_photo"
+
+	TypeCaptureReminder = "capture_reminder"
+	TypeLastChance      = "last_chance"
+
+	TypeComment  = "comment"
+	TypeReaction = "reaction"
+	TypeNudge    = "nudge"
+)
+
+const (
+	// Expo keeps receipts for a day and recommends waiting before checking them
+	receiptDelay        = 15 * time.Minute
+	receiptPollInterval = 15 * time.Minute
+	ticketMaxAge        = 24 * time.Hour
+)
+
+// Notification is what a user is told, independent of how it is delivered.
+type Notification struct {
+	Type  string
+	Title string
+	Body  string
+	Data  map[string]any
+	// GroupID is set when the notification is about a group, so muting it applies
+	GroupID string
+	// ActorID and PhotoID link the notification to who caused it and what it is about
+	ActorID string
+	PhotoID string
+}
+
+// Service sends pushes to every registered device of a user and keeps the device
+// table clean using Expo's tickets and receipts. It doesn't check preferences, so
+// callers go through a Dispatcher.
+type Service struct {
+	db     *pgxpool.Pool
+	client *ExpoClient
+}
+
+func NewService(db *pgxpool.Pool, client *ExpoClient) *Service {
+	return &Service{db: db, client: client}
+}
+
+// Send pushes n to all devices of the given users. Tokens Expo rejects outright are
+// removed; accepted tickets are stored so their receipts can be checked later.
+func (s *Service) Send(ctx context.Context, userIDs []string, n Notification) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	rows, err := s.db.Query(ctx,
+		"SELECT token FROM device_tokens WHERE user_id::text = ANY($1)",
+		userIDs,
+	)
+	if err != nil {
+		return err
+	}
+	tokens := make([]string, 0)
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			rows.Close()
+			return err
+		}
+		tokens = append(tokens, token)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	data := map[string]any{"type": n.Type}
+	for k, v := range n.Data {
+		data[k] = v
+	}
+
+	messages := make([]Message, len(tokens))
+	for i, token := range tokens {
+		messages[i] = Message{To: token, Title: n.Title, Body: n.Body, Data: data, Sound: "default"}
+	}
+
+	tickets, err := s.client.Send(ctx, messages)
+	// tickets line up with the messages that made it out, even on a partial failure
+	for i, ticket := range tickets {
+		s.handleTicket(ctx, tokens[i], ticket)
+	}
+	return err
+}
+
+func (s *Service) handleTicket(ctx context.Context, token string, ticket Ticket) {
+	var err error
+	switch {
+	case ticket.Status == "ok" && ticket.ID != "":
+		_, err = s.db.Exec(ctx,
+			"INSERT INTO push_tickets (id, token) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			ticket.ID, token,
+		)
+	case ticket.Details.Error == ErrorDeviceNotRegistered:
+		err = s.removeToken(ctx, token)
+	case ticket.Status == "error":
+		log.Printf("Push to %s failed: %s", token, ticket.Message)
+	}
+	if err != nil {
+		log.Printf("Failed to record push ticket: %v", err)
+	}
+}
+
+func (s *Service) removeToken(ctx context.Context, token string) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM device_tokens WHERE token = $1", token)
+	return err
+}
+
+// PollReceipts checks tickets old enough to have receipts, prunes tokens of
+// unregistered devices and forgets every ticket that has an answer or is too old.
+func (s *Service) PollReceipts(ctx context.Context) error {
+	rows, err := s.db.Query(ctx,
+		"SELECT id, token FROM push_tickets WHERE created_at < $1",
+		time.Now().Add(-receiptDelay),
+	)
+	if err != nil {
+		return err
+	}
+	tokenByTicket := make(map[string]string)
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id, token string
+		if err := rows.Scan(&id, &token); err != nil {
+			rows.Close()
+			return err
+		}
+		tokenByTicket[id] = token
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	receipts, err := s.client.GetReceipts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	done := make([]string, 0, len(receipts))
+	for id, receipt := range receipts {
+		if receipt.Details.Error == ErrorDeviceNotRegistered {
+			if err := s.removeToken(ctx, tokenByTicket[id]); err != nil {
+				return err
+			}
+		} else if receipt.Status == "error" {
+			log.Printf("Push receipt %s failed: %s", id, receipt.Message)
+		}
+		done = append(done, id)
+	}
+
+	_, err = s.db.Exec(ctx,
+		"DELETE FROM push_tickets WHERE id = ANY($1) OR created_at < $2",
+		done, time.Now().Add(-ticketMaxAge),
+	)
+	return err
+}
+
+// RunReceiptPoller polls receipts periodically until ctx ends.
+func (s *Service) RunReceiptPoller(ctx context.Context) {
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.PollReceipts(ctx); err != nil {
+				log.Printf("Push receipt poll failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	type call struct {
+		key       string
+		at        time.Duration // after start
+		allowed   bool
+		wantRetry time.Duration
+	}
+	tests := []struct {
+		name  string
+		calls []call
+	}{
+		{"up to the limit", []call{
+			{"ada", 0, true, 0},
+			{"ada", time.Second, true, 0},
+			{"ada", 2 * time.Second, true, 0},
+			{"ada", 3 * time.Second, false, 57 * time.Second},
+		}},
+		{"keys are separate", []call{
+			{"ada", 0, true, 0},
+			{"ada", 0, true, 0},
+			{"ada", 0, true, 0},
+			{"grace", 0, true, 0},
+			{"ada", 0, false, time.Minute},
+		}},
+		{"window resets", []call{
+			{"ada", 0, true, 0},
+			{"ada", 0, true, 0},
+			{"ada", 0, true, 0},
+			{"ada", 59 * time.Second, false, time.Second},
+			{"ada", time.Minute, true, 0},
+		}},
+		{"refusals don't extend the window", []call{
+			{"ada", 0, true, 0},
+			{"ada", 0, true, 0},
+			{"ada", 0, true, 0},
+			{"ada", 30 * time.Second, false, 30 * time.Second},
+			{"ada", 45 * time.Second, false, 15 * time.Second},
+			{"ada", time.Minute, true, 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(3, time.Minute)
+			for i, c := range tt.calls {
+				l.now = func() time.Time { return start.Add(c.at) }
+				allowed, retry := l.Allow(c.key)
+				if allowed != c.allowed || retry != c.wantRetry {
+					t.Errorf("call %d (%s at %v) = %v, %v; want %v, %v", i, c.key, c.at, allowed, retry, c.allowed, c.wantRetry)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterSweepsIdleKeys(t *testing.T) {
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	l := New(1, time.Minute)
+
+	l.now = func() time.Time { return start }
+	for _, key := range []string{"a", "b", "c"} {
+		l.Allow(key)
+	}
+	l.now = func() time.Time { return start.Add(2 * time.Minute) }
+	l.Allow("d")
+
+	if len(l.windows) != 1 {
+		t.Errorf("holding %d windows after they expired, want 1", len(l.windows))
+	}
+}
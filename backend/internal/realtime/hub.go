@@ -0,0 +1,105 @@
+package realtime
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Channel is the Postgres channel a notification is sent on whenever a feed event
+// is recorded.
+const Channel = "feed_events"
+
+const reconnectDelay = 5 * time.Second
+
+// Hub keeps one LISTEN connection per API instance and wakes every local stream
+// when any instance records an event. The notification carries no event: streams
+// read the events table themselves, so a wake-up lost while reconnecting only
+// delays delivery and never drops anything.
+type Hub struct {
+	db *pgxpool.Pool
+
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+func NewHub(db *pgxpool.Pool) *Hub {
+	return &Hub{
+		db:          db,
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives a value after new events may have been
+// recorded. Wake-ups coalesce, so a slow reader sees one for several events. The
+// returned func must be called once the subscriber is done.
+func (h *Hub) Subscribe() (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	h.subscribers[wake] = struct{}{}
+	h.mu.Unlock()
+
+	return wake, func() {
+		h.mu.Lock()
+		delete(h.subscribers, wake)
+		h.mu.Unlock()
+	}
+}
+
+func (h *Hub) wakeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for wake := range h.subscribers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// listen holds a dedicated connection until it fails or ctx ends.
+func (h *Hub) listen(ctx context.Context) error {
+	conn, err := h.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// a connection that has run LISTEN must not go back to the pool
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+
+	if _, err := pgConn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+
+	// anything recorded while we weren't listening is picked up now
+	h.wakeAll()
+
+	for {
+		if _, err := pgConn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		h.wakeAll()
+	}
+}
+
+// Run listens for events until ctx ends, reconnecting after failures.
+func (h *Hub) Run(ctx context.Context) {
+	for {
+		err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Feed event listener stopped, reconnecting: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
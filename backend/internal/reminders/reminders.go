@@ -0,0 +1,255 @@
+package reminders
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/notifications"
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/slots"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	KindOpen       = "open"
+	KindLastChance = "last_chance"
+
+	tickInterval          = 15 * time.Second
+	defaultLastChanceLead = 3 * time.Minute
+	runRetention          = 7 * 24 * time.Hour
+	// a failed run is claimed again after this, as long as its window is open
+	retryDelay = time.Minute
+	// a run still claimed after this is assumed to have died with its instance
+	claimLease = 2 * time.Minute
+	// users are recorded as reminded after each batch, so a retry resends at most one
+	batchSize = 100
+)
+
+// Scheduler sends a reminder when each slot's submission window opens and a last
+// chance reminder shortly before it closes, skipping users who already posted.
+// Any number of instances can run it: each send is claimed through reminder_runs
+// first, so only one instance at a time sends a given reminder. Runs that fail are
+// claimed again while their window is still open and send only to the users the
+// failed attempt didn't reach.
+type Scheduler struct {
+	db         *pgxpool.Pool
+	dispatcher *notifications.Dispatcher
+	// instance identifies this process in reminder_runs
+	instance string
+	// LastChanceLead is how long before the window closes the last chance reminder
+	// goes out. Zero disables it.
+	LastChanceLead time.Duration
+}
+
+// NewScheduler reads REMINDER_LAST_CHANCE_MINUTES, where 0 turns off last chance reminders.
+func NewScheduler(db *pgxpool.Pool, dispatcher *notifications.Dispatcher) *Scheduler {
+	lead := defaultLastChanceLead
+	if minutes, err := strconv.Atoi(os.Getenv("REMINDER_LAST_CHANCE_MINUTES")); err == nil && minutes >= 0 {
+		lead = time.Duration(minutes) * time.Minute
+	}
+	if lead >= slots.SubmissionWindowDuration {
+		lead = defaultLastChanceLead
+	}
+
+	hostname, _ := os.Hostname()
+	id, _ := uuid.NewV7()
+	return &Scheduler{
+		db:             db,
+		dispatcher:     dispatcher,
+		instance:       hostname + "/" + id.String(),
+		LastChanceLead: lead,
+	}
+}
+
+// dueKind returns which reminder, if any, should be going out for slot at now.
+func (s *Scheduler) dueKind(slot, now time.Time) string {
+	if !slots.IsWithinSubmissionWindow(slot, now) {
+		return ""
+	}
+
+	_, closesAt := slots.GetSubmissionWindow(slot)
+	if s.LastChanceLead > 0 && !now.Before(closesAt.Add(-s.LastChanceLead)) {
+		return KindLastChance
+	}
+	return KindOpen
+}
+
+// claim records the run and reports whether this instance won it. A run that
+// failed, or whose claimer went quiet, can be won again.
+func (s *Scheduler) claim(ctx context.Context, slot time.Time, kind string) (bool, error) {
+	tag, err := s.db.Exec(ctx,
+		`INSERT INTO reminder_runs (slot, kind, claimed_by) VALUES ($1, $2, $3)
+		 ON CONFLICT (slot, kind) DO UPDATE
+		 SET claimed_by = EXCLUDED.claimed_by, claimed_at = NOW(), status = 'claimed',
+			error = NULL, finished_at = NULL
+		 WHERE (reminder_runs.status = 'failed' AND reminder_runs.finished_at < NOW() - $4 * INTERVAL '1 second')
+		 OR (reminder_runs.status = 'claimed' AND reminder_runs.claimed_at < NOW() - $5 * INTERVAL '1 second')`,
+		slot, kind, s.instance, retryDelay.Seconds(), claimLease.Seconds(),
+	)
+	return tag.RowsAffected() == 1, err
+}
+
+// finish records how a claimed run ended.
+func (s *Scheduler) finish(ctx context.Context, slot time.Time, kind string, sendErr error) error {
+	status := "sent"
+	var errText *string
+	if sendErr != nil {
+		status = "failed"
+		msg := sendErr.Error()
+		errText = &msg
+	}
+
+	_, err := s.db.Exec(ctx,
+		`UPDATE reminder_runs SET status = $3, error = $4, finished_at = NOW()
+		 WHERE slot = $1 AND kind = $2 AND claimed_by = $5`,
+		slot, kind, status, errText, s.instance,
+	)
+	return err
+}
+
+// recipients are users with a device who belong to a group, have no photo in slot and
+// haven't been sent this reminder yet.
+func (s *Scheduler) recipients(ctx context.Context, slot time.Time, kind string) ([]string, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT DISTINCT dt.user_id::text
+		 FROM device_tokens dt
+		 WHERE EXISTS (SELECT 1 FROM group_members gm WHERE gm.user_id = dt.user_id)
+		 AND NOT EXISTS (
+			SELECT 1 FROM photos p
+			WHERE p.user_id = dt.user_id AND p.hour_timestamp = $1
+		 )
+		 AND NOT EXISTS (
+			SELECT 1 FROM reminder_deliveries rd
+			WHERE rd.slot = $1 AND rd.kind = $2 AND rd.user_id = dt.user_id
+		 )`,
+		slot, kind,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
+
+func reminderFor(slot time.Time, kind string) notifications.Notification {
+	n := notifications.Notification{
+		Type:  notifications.TypeCaptureReminder,
+		Title: "Time to SNAPSHOT",
+		Body:  "A new window just opened, take your photo.",
+		Data:  map[string]any{"slot": slot.Format(time.RFC3339)},
+	}
+	if kind == KindLastChance {
+		n.Type = notifications.TypeLastChance
+		n.Title = "Last chance"
+		n.Body = "The window closes in a few minutes."
+	}
+	return n
+}
+
+func (s *Scheduler) tick(ctx context.Context, now time.Time) error {
+	slot := now.UTC().Truncate(time.Hour)
+	kind := s.dueKind(slot, now)
+	if kind == "" {
+		return nil
+	}
+
+	won, err := s.claim(ctx, slot, kind)
+	if err != nil || !won {
+		return err
+	}
+
+	sendErr := s.send(ctx, slot, kind)
+	if err := s.finish(ctx, slot, kind, sendErr); err != nil {
+		return err
+	}
+	return sendErr
+}
+
+// batches splits userIDs into consecutive runs of at most size.
+func batches(userIDs []string, size int) [][]string {
+	out := make([][]string, 0, (len(userIDs)+size-1)/size)
+	for start := 0; start < len(userIDs); start += size {
+		out = append(out, userIDs[start:min(start+size, len(userIDs))])
+	}
+	return out
+}
+
+func (s *Scheduler) send(ctx context.Context, slot time.Time, kind string) error {
+	userIDs, err := s.recipients(ctx, slot, kind)
+	if err != nil {
+		return err
+	}
+
+	// a retried run counts the users earlier attempts reached too
+	_, err = s.db.Exec(ctx,
+		`UPDATE reminder_runs SET recipients = $1 + (
+			SELECT COUNT(*) FROM reminder_deliveries WHERE slot = $2 AND kind = $3
+		 )
+		 WHERE slot = $2 AND kind = $3`,
+		len(userIDs), slot, kind,
+	)
+	if err != nil {
+		return err
+	}
+
+	n := reminderFor(slot, kind)
+	for _, batch := range batches(userIDs, batchSize) {
+		if err := s.dispatcher.Dispatch(ctx, batch, n); err != nil {
+			return err
+		}
+		_, err := s.db.Exec(ctx,
+			`INSERT INTO reminder_deliveries (slot, kind, user_id)
+			 SELECT $1, $2, id FROM unnest($3::uuid[]) AS id
+			 ON CONFLICT DO NOTHING`,
+			slot, kind, batch,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prune forgets runs old enough that they can never be claimed again.
+func (s *Scheduler) prune(ctx context.Context, now time.Time) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM reminder_runs WHERE slot < $1", now.Add(-runRetention))
+	return err
+}
+
+// Run checks for due reminders until ctx ends.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	lastPrune := time.Time{}
+	for {
+		now := time.Now()
+		if err := s.tick(ctx, now); err != nil {
+			log.Printf("Reminder run failed: %v", err)
+		}
+		if now.Sub(lastPrune) > time.Hour {
+			if err := s.prune(ctx, now); err != nil {
+				log.Printf("Reminder prune failed: %v", err)
+			}
+			lastPrune = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package reminders
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDueKind(t *testing.T) {
+	slot := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		lead time.Duration
+		now  time.Time
+		want string
+	}{
+		{"before the window", 3 * time.Minute, slot.Add(-time.Second), ""},
+		{"window opens", 3 * time.Minute, slot, KindOpen},
+		{"mid window", 3 * time.Minute, slot.Add(6 * time.Minute), KindOpen},
+		{"last chance starts", 3 * time.Minute, slot.Add(7 * time.Minute), KindLastChance},
+		{"window closes", 3 * time.Minute, slot.Add(10 * time.Minute), KindLastChance},
+		{"after the window", 3 * time.Minute, slot.Add(10*time.Minute + time.Second), ""},
+		{"last chance off", 0, slot.Add(9 * time.Minute), KindOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scheduler{LastChanceLead: tt.lead}
+			if got := s.dueKind(slot, tt.now); got != tt.want {
+				t.Errorf("dueKind at %v = %q, want %q", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSchedulerLastChanceLead(t *testing.T) {
+	tests := []struct {
+		env  string
+		want time.Duration
+	}{
+		{"", defaultLastChanceLead},
+		{"5", 5 * time.Minute},
+		{"0", 0},
+		{"-1", defaultLastChanceLead},
+		{"soon", defaultLastChanceLead},
+		{"10", defaultLastChanceLead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv("REMINDER_LAST_CHANCE_MINUTES", tt.env)
+			if got := NewScheduler(nil, nil).LastChanceLead; got != tt.want {
+				t.Errorf("LastChanceLead = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatches(t *testing.T) {
+	ids := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = strconv.Itoa(i)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		users int
+		size  int
+		want  []int
+	}{
+		{"none", 0, 100, []int{}},
+		{"one short batch", 3, 100, []int{3}},
+		{"exactly full", 100, 100, []int{100}},
+		{"remainder", 250, 100, []int{100, 100, 50}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userIDs := ids(tt.users)
+			got := batches(userIDs, tt.size)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d batches, want %d", len(got), len(tt.want))
+			}
+			next := 0
+			for i, batch := range got {
+				if len(batch) != tt.want[i] {
+					t.Errorf("batch %d has %d users, want %d", i, len(batch), tt.want[i])
+				}
+				// every user lands in exactly one batch, in order
+				for _, id := range batch {
+					if id != userIDs[next] {
+						t.Fatalf("batch %d holds %s, want %s", i, id, userIDs[next])
+					}
+					next++
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package slots
+
+import "time"
+
+const SubmissionWindowDuration = 10 * time.Minute
+
+func GetCurrentHourSlot() time.Time {
+	return time.Now().UTC().Truncate(time.Hour)
+}
+
+func GetNextHourSlot(slot time.Time) time.Time {
+	return slot.Add(time.Hour)
+}
+
+// GetSubmissionWindow returns the instants a slot's submission window opens and closes.
+func GetSubmissionWindow(slot time.Time) (time.Time, time.Time) {
+	return slot, slot.Add(SubmissionWindowDuration)
+}
+
+func IsValidSubmissionWindow(slot time.Time) bool {
+	return IsWithinSubmissionWindow(slot, time.Now().UTC())
+}
+
+// IsWithinSubmissionWindow reports whether now falls inside the slot's window.
+func IsWithinSubmissionWindow(slot time.Time, now time.Time) bool {
+	opensAt, deadline := GetSubmissionWindow(slot)
+
+	if now.Before(opensAt) {
+		return false
+	}
+	if now.After(deadline) {
+		return false
+	}
+	return true
+}
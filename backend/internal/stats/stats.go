@@ -0,0 +1,327 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Aayaan-Sahu/SNAPSHOT/internal/slots"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const slotsPerDay = 24
+
+// DBTX is satisfied by both *pgxpool.Pool and pgx.Tx.
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type DayStats struct {
+	Date     string `json:"date"`
+	Captured int    `json:"captured"`
+	Missed   int    `json:"missed"`
+}
+
+type UserStats struct {
+	UserID        string     `json:"user_id"`
+	CurrentStreak int        `json:"current_streak"`
+	LongestStreak int        `json:"longest_streak"`
+	TotalCaptured int        `json:"total_captured"`
+	LastSlot      *string    `json:"last_slot"`
+	Today         DayStats   `json:"today"`
+	Week          DayStats   `json:"week"`
+	Days          []DayStats `json:"days"` // oldest first, ending today
+}
+
+type LeaderboardEntry struct {
+	UserID        string  `json:"user_id"`
+	Name          string  `json:"name"`
+	Picture       *string `json:"picture"`
+	CurrentStreak int     `json:"current_streak"`
+	LongestStreak int     `json:"longest_streak"`
+	CapturedToday int     `json:"captured_today"`
+	CapturedWeek  int     `json:"captured_week"`
+	// nudges within this group over the same week
+	NudgesSentWeek     int `json:"nudges_sent_week"`
+	NudgesReceivedWeek int `json:"nudges_received_week"`
+}
+
+// streak is the running streak kept in user_stats.
+type streak struct {
+	Current  int
+	Longest  int
+	LastSlot time.Time // zero before the first capture
+}
+
+// extend folds a capture in slot into s. A slot right after the last one grows the
+// streak, an older slot being confirmed late leaves it alone and anything else
+// starts a new one.
+func (s streak) extend(slot time.Time) streak {
+	switch {
+	case !s.LastSlot.IsZero() && s.LastSlot.Equal(slot.Add(-time.Hour)):
+		s.Current++
+	case !s.LastSlot.IsZero() && !s.LastSlot.Before(slot):
+		// confirmed late, the streak already covers it
+	default:
+		s.Current = 1
+	}
+	s.Longest = max(s.Longest, s.Current)
+	if slot.After(s.LastSlot) {
+		s.LastSlot = slot
+	}
+	return s
+}
+
+// RecordCapture folds a newly confirmed slot into the user's running stats.
+// It must run in the same transaction as the photo insert.
+func RecordCapture(ctx context.Context, q DBTX, userID string, slot time.Time) error {
+	slot = slot.UTC()
+
+	var s streak
+	var lastSlot *time.Time
+	err := q.QueryRow(ctx,
+		`SELECT current_streak, longest_streak, last_slot
+		 FROM user_stats WHERE user_id = $1
+		 FOR UPDATE`,
+		userID,
+	).Scan(&s.Current, &s.Longest, &lastSlot)
+	if err != nil && err != pgx.ErrNoRows {
+		return fmt.Errorf("failed to load user stats: %w", err)
+	}
+	if lastSlot != nil {
+		s.LastSlot = lastSlot.UTC()
+	}
+	s = s.extend(slot)
+
+	_, err = q.Exec(ctx,
+		`INSERT INTO user_stats (user_id, current_streak, longest_streak, last_slot, total_captured)
+		 VALUES ($1, $2, $3, $4, 1)
+		 ON CONFLICT (user_id) DO UPDATE SET
+			current_streak = EXCLUDED.current_streak,
+			longest_streak = EXCLUDED.longest_streak,
+			last_slot = EXCLUDED.last_slot,
+			total_captured = user_stats.total_captured + 1,
+			updated_at = NOW()`,
+		userID, s.Current, s.Longest, s.LastSlot,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user stats: %w", err)
+	}
+
+	_, err = q.Exec(ctx,
+		`INSERT INTO user_daily_stats (user_id, day, captured) VALUES ($1, $2, 1)
+		 ON CONFLICT (user_id, day) DO UPDATE SET captured = user_daily_stats.captured + 1`,
+		userID, slot.Truncate(24*time.Hour),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update daily stats: %w", err)
+	}
+
+	return nil
+}
+
+// Rebuild recomputes a user's stats from their photos. It is only needed when
+// history changes, such as a photo being deleted.
+func Rebuild(ctx context.Context, q DBTX, userID string) error {
+	_, err := q.Exec(ctx, "DELETE FROM user_daily_stats WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to clear daily stats: %w", err)
+	}
+
+	_, err = q.Exec(ctx,
+		`INSERT INTO user_daily_stats (user_id, day, captured)
+		 SELECT user_id, (hour_timestamp AT TIME ZONE 'UTC')::date, COUNT(*)
+		 FROM photos
+		 WHERE user_id = $1
+		 GROUP BY user_id, (hour_timestamp AT TIME ZONE 'UTC')::date`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild daily stats: %w", err)
+	}
+
+	// consecutive slots share the same island once offset by their row number
+	_, err = q.Exec(ctx,
+		`WITH ordered AS (
+			SELECT hour_timestamp,
+				hour_timestamp - (ROW_NUMBER() OVER (ORDER BY hour_timestamp)) * INTERVAL '1 hour' AS island
+			FROM photos
+			WHERE user_id = $1
+		 ), islands AS (
+			SELECT COUNT(*) AS len, MAX(hour_timestamp) AS last_slot
+			FROM ordered
+			GROUP BY island
+		 ), summary AS (
+			SELECT
+				COALESCE((SELECT len FROM islands ORDER BY last_slot DESC LIMIT 1), 0) AS current_streak,
+				COALESCE(MAX(len), 0) AS longest_streak,
+				MAX(last_slot) AS last_slot,
+				COALESCE(SUM(len), 0) AS total_captured
+			FROM islands
+		 )
+		 INSERT INTO user_stats (user_id, current_streak, longest_streak, last_slot, total_captured)
+		 SELECT $1, current_streak, longest_streak, last_slot, total_captured FROM summary
+		 ON CONFLICT (user_id) DO UPDATE SET
+			current_streak = EXCLUDED.current_streak,
+			longest_streak = EXCLUDED.longest_streak,
+			last_slot = EXCLUDED.last_slot,
+			total_captured = EXCLUDED.total_captured,
+			updated_at = NOW()`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild user stats: %w", err)
+	}
+
+	return nil
+}
+
+// StreakCutoff is the oldest last_slot that still counts as an unbroken streak.
+// While the current window is open the previous slot keeps the streak alive.
+func StreakCutoff(now time.Time) time.Time {
+	currentSlot := now.UTC().Truncate(time.Hour)
+	if slots.IsWithinSubmissionWindow(currentSlot, now) {
+		return currentSlot.Add(-time.Hour)
+	}
+	return currentSlot
+}
+
+// slotsElapsed counts the slots of a UTC day whose windows have already closed.
+func slotsElapsed(day time.Time, now time.Time) int {
+	if now.Before(day) {
+		return 0
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	if day.Before(today) {
+		return slotsPerDay
+	}
+
+	currentSlot := now.UTC().Truncate(time.Hour)
+	elapsed := currentSlot.Hour()
+	if !slots.IsWithinSubmissionWindow(currentSlot, now) {
+		elapsed++
+	}
+	return elapsed
+}
+
+func GetUserStats(ctx context.Context, q DBTX, userID string, now time.Time) (UserStats, error) {
+	now = now.UTC()
+	currentSlot := now.Truncate(time.Hour)
+	today := now.Truncate(24 * time.Hour)
+	weekStart := today.AddDate(0, 0, -6)
+
+	stats := UserStats{UserID: userID}
+
+	var lastSlot *time.Time
+	err := q.QueryRow(ctx,
+		`SELECT current_streak, longest_streak, last_slot, total_captured
+		 FROM user_stats WHERE user_id = $1`,
+		userID,
+	).Scan(&stats.CurrentStreak, &stats.LongestStreak, &lastSlot, &stats.TotalCaptured)
+	if err != nil && err != pgx.ErrNoRows {
+		return stats, fmt.Errorf("failed to load user stats: %w", err)
+	}
+
+	postedCurrent := false
+	if lastSlot != nil {
+		formatted := lastSlot.UTC().Format(time.RFC3339)
+		stats.LastSlot = &formatted
+		postedCurrent = lastSlot.Equal(currentSlot)
+		if lastSlot.Before(StreakCutoff(now)) {
+			stats.CurrentStreak = 0
+		}
+	}
+
+	rows, err := q.Query(ctx,
+		`SELECT day, captured FROM user_daily_stats
+		 WHERE user_id = $1 AND day >= $2 AND day <= $3`,
+		userID, weekStart, today,
+	)
+	if err != nil {
+		return stats, fmt.Errorf("failed to load daily stats: %w", err)
+	}
+	defer rows.Close()
+
+	captured := make(map[string]int)
+	for rows.Next() {
+		var day time.Time
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			continue
+		}
+		captured[day.Format("2006-01-02")] = count
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("failed to read daily stats: %w", err)
+	}
+
+	stats.Days = make([]DayStats, 0, 7)
+	stats.Week = DayStats{Date: weekStart.Format("2006-01-02")}
+	for day := weekStart; !day.After(today); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		count := captured[date]
+
+		// a capture in the still-open slot is not part of the elapsed slots yet
+		closedCaptures := count
+		if day.Equal(today) && postedCurrent && slots.IsWithinSubmissionWindow(currentSlot, now) {
+			closedCaptures--
+		}
+
+		ds := DayStats{
+			Date:     date,
+			Captured: count,
+			Missed:   max(0, slotsElapsed(day, now)-closedCaptures),
+		}
+		stats.Days = append(stats.Days, ds)
+		stats.Week.Captured += ds.Captured
+		stats.Week.Missed += ds.Missed
+	}
+	stats.Today = stats.Days[len(stats.Days)-1]
+
+	return stats, nil
+}
+
+// GetGroupLeaderboard ranks a group's members by their current streak.
+func GetGroupLeaderboard(ctx context.Context, q DBTX, groupID string, now time.Time) ([]LeaderboardEntry, error) {
+	now = now.UTC()
+	today := now.Truncate(24 * time.Hour)
+	weekStart := today.AddDate(0, 0, -6)
+
+	rows, err := q.Query(ctx,
+		`SELECT u.id, u.name, u.picture,
+			CASE WHEN s.last_slot >= $2 THEN s.current_streak ELSE 0 END AS current_streak,
+			COALESCE(s.longest_streak, 0),
+			COALESCE((SELECT captured FROM user_daily_stats d WHERE d.user_id = u.id AND d.day = $3), 0),
+			COALESCE((SELECT SUM(captured) FROM user_daily_stats d WHERE d.user_id = u.id AND d.day >= $4 AND d.day <= $3), 0) AS captured_week,
+			(SELECT COUNT(*) FROM nudges n WHERE n.group_id = gm.group_id AND n.sender_id = u.id AND n.created_at >= $4),
+			(SELECT COUNT(*) FROM nudges n WHERE n.group_id = gm.group_id AND n.recipient_id = u.id AND n.created_at >= $4)
+		 FROM group_members gm
+		 JOIN users u ON u.id = gm.user_id
+		 LEFT JOIN user_stats s ON s.user_id = u.id
+		 WHERE gm.group_id = $1
+		 ORDER BY current_streak DESC, captured_week DESC, lower(u.name) ASC`,
+		groupID, StreakCutoff(now), today, weekStart,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]LeaderboardEntry, 0)
+	for rows.Next() {
+		var e LeaderboardEntry
+		if err := rows.Scan(&e.UserID, &e.Name, &e.Picture, &e.CurrentStreak, &e.LongestStreak, &e.CapturedToday, &e.CapturedWeek,
+			&e.NudgesSentWeek, &e.NudgesReceivedWeek); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read leaderboard: %w", err)
+	}
+
+	return entries, nil
+}
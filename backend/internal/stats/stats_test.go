@@ -0,0 +1,78 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreakExtend(t *testing.T) {
+	slot := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		in   streak
+		want streak
+	}{
+		{"first capture", streak{}, streak{Current: 1, Longest: 1, LastSlot: slot}},
+		{"next slot", streak{Current: 3, Longest: 5, LastSlot: slot.Add(-time.Hour)}, streak{Current: 4, Longest: 5, LastSlot: slot}},
+		{"new longest", streak{Current: 5, Longest: 5, LastSlot: slot.Add(-time.Hour)}, streak{Current: 6, Longest: 6, LastSlot: slot}},
+		{"missed a slot", streak{Current: 7, Longest: 7, LastSlot: slot.Add(-2 * time.Hour)}, streak{Current: 1, Longest: 7, LastSlot: slot}},
+		{"older slot confirmed late", streak{Current: 2, Longest: 4, LastSlot: slot.Add(time.Hour)}, streak{Current: 2, Longest: 4, LastSlot: slot.Add(time.Hour)}},
+		{"same slot again", streak{Current: 2, Longest: 4, LastSlot: slot}, streak{Current: 2, Longest: 4, LastSlot: slot}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.extend(slot); got != tt.want {
+				t.Errorf("extend = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreakCutoff(t *testing.T) {
+	slot := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"window open", slot.Add(5 * time.Minute), slot.Add(-time.Hour)},
+		{"window closing", slot.Add(10 * time.Minute), slot.Add(-time.Hour)},
+		{"window closed", slot.Add(11 * time.Minute), slot},
+		{"other timezone", slot.Add(30 * time.Minute).In(time.FixedZone("PDT", -7*3600)), slot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StreakCutoff(tt.now); !got.Equal(tt.want) {
+				t.Errorf("StreakCutoff(%v) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlotsElapsed(t *testing.T) {
+	today := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		day  time.Time
+		now  time.Time
+		want int
+	}{
+		{"past day", today.AddDate(0, 0, -1), today.Add(3 * time.Hour), 24},
+		{"future day", today.AddDate(0, 0, 1), today.Add(3 * time.Hour), 0},
+		{"first window open", today, today.Add(5 * time.Minute), 0},
+		{"first window closed", today, today.Add(20 * time.Minute), 1},
+		{"midday window open", today, today.Add(12*time.Hour + time.Minute), 12},
+		{"midday window closed", today, today.Add(12*time.Hour + 30*time.Minute), 13},
+		{"last window closed", today, today.Add(23*time.Hour + 59*time.Minute), 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slotsElapsed(tt.day, tt.now); got != tt.want {
+				t.Errorf("slotsElapsed(%v, %v) = %d, want %d", tt.day, tt.now, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	EventPhotoConfirmed = "photo.confirmed"
+	EventMemberJoined   = "member.joined"
+	EventMemberLeft     = "member.left"
+	EventGroupUpdated   = "group.updated"
+
+	SignatureHeader = "X-Snapshot-Signature"
+	EventHeader     = "X-Snapshot-Event"
+	DeliveryHeader  = "X-Snapshot-Delivery"
+
+	deliveryTimeout = 10 * time.Second
+	// only this much of a response is kept in the delivery log
+	maxResponseExcerpt = 512
+)
+
+// EventTypes are the events a webhook can subscribe to.
+var EventTypes = []string{EventPhotoConfirmed, EventMemberJoined, EventMemberLeft, EventGroupUpdated}
+
+var ErrPrivateAddress = errors.New("webhook URL resolves to a private address")
+
+// Sign returns the signature header value for body sent at t. Receivers recompute
+// the HMAC-SHA256 of "<t>.<body>" with their secret and compare it to v1, and can
+// reject old timestamps to stop replays.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// allowPrivate lets webhooks reach localhost and private networks, for development.
+func allowPrivate() bool {
+	return os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"
+}
+
+// ValidateURL checks a webhook URL is absolute and https. Plain http is accepted
+// when private addresses are allowed, for local receivers.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("url must be an absolute URL")
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && allowPrivate()) {
+		return errors.New("url must use https")
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() || ip.IsInterfaceLocalMulticast())
+}
+
+// Client posts signed events. Unless WEBHOOK_ALLOW_PRIVATE is set it refuses to
+// connect to private addresses, checked after DNS resolution so a public name
+// can't point it at the internal network.
+type Client struct {
+	http *http.Client
+}
+
+func NewClient() *Client {
+	dialer := &net.Dialer{Timeout: deliveryTimeout}
+	if !allowPrivate() {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &Client{http: &http.Client{
+		Timeout:   deliveryTimeout,
+		Transport: transport,
+		// a redirect could lead anywhere, receivers must give the final URL
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Result is what a delivery attempt got back. StatusCode is 0 when no response
+// arrived.
+type Result struct {
+	StatusCode int
+	Excerpt    string
+}
+
+// Post sends body to url and reports whether the receiver accepted it with a 2xx.
+func (c *Client) Post(ctx context.Context, targetURL, secret, eventType, deliveryID string, body []byte) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SNAPSHOT-Webhooks/1.0")
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, deliveryID)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseExcerpt))
+	result := Result{StatusCode: resp.StatusCode, Excerpt: string(excerpt)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("receiver responded %d", resp.StatusCode)
+	}
+	return result, nil
+}
@@ -0,0 +1,311 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	MaxAttempts = 8
+
+	tickInterval = 5 * time.Second
+	fanOutBatch  = 100
+	deliverBatch = 20
+	baseBackoff  = 30 * time.Second
+	maxBackoff   = 6 * time.Hour
+	// a claimed delivery becomes due again after this, in case its worker died
+	// mid attempt; it must outlast deliveryTimeout
+	claimLease = 2 * time.Minute
+
+	deliveryRetention = 30 * 24 * time.Hour
+	outboxRetention   = 7 * 24 * time.Hour
+)
+
+// Backoff is the wait before retrying after the given number of failed attempts:
+// 30s, 1m, 2m and so on up to 6h, plus up to 20% jitter so retries spread out.
+func Backoff(attempts int) time.Duration {
+	wait := maxBackoff
+	if attempts < 20 {
+		wait = min(baseBackoff<<(max(attempts, 1)-1), maxBackoff)
+	}
+	return wait + time.Duration(rand.Int64N(int64(wait/5)+1))
+}
+
+// Envelope is the JSON body of every webhook request. ID identifies the event and
+// stays the same across retries and redeliveries, so receivers can dedupe on it.
+type Envelope struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	GroupID   string          `json:"group_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Worker turns outbox rows into deliveries and sends them, retrying failures with
+// exponential backoff. Rows are claimed with SKIP LOCKED, so any number of
+// instances can run it side by side.
+type Worker struct {
+	db     *pgxpool.Pool
+	client *Client
+}
+
+func NewWorker(db *pgxpool.Pool, client *Client) *Worker {
+	return &Worker{db: db, client: client}
+}
+
+// fanOut creates a delivery for every active webhook subscribed to each pending
+// outbox event, in the same transaction that marks the events processed.
+func (w *Worker) fanOut(ctx context.Context) (int, error) {
+	tx, err := w.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	rows, err := tx.Query(ctx,
+		`SELECT id, group_id::text, type, data, created_at
+		 FROM webhook_outbox
+		 WHERE processed_at IS NULL
+		 ORDER BY id
+		 LIMIT $1
+		 FOR UPDATE SKIP LOCKED`,
+		fanOutBatch,
+	)
+	if err != nil {
+		return 0, err
+	}
+	events := make([]Envelope, 0)
+	for rows.Next() {
+		var e Envelope
+		if err := rows.Scan(&e.ID, &e.GroupID, &e.Type, &e.Data, &e.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return 0, err
+		}
+
+		hookRows, err := tx.Query(ctx,
+			`SELECT id FROM group_webhooks
+			 WHERE group_id = $1 AND active
+			 AND (cardinality(events) = 0 OR $2 = ANY(events))`,
+			e.GroupID, e.Type,
+		)
+		if err != nil {
+			return 0, err
+		}
+		hookIDs := make([]string, 0)
+		for hookRows.Next() {
+			var id string
+			if err := hookRows.Scan(&id); err != nil {
+				hookRows.Close()
+				return 0, err
+			}
+			hookIDs = append(hookIDs, id)
+		}
+		hookRows.Close()
+		if err := hookRows.Err(); err != nil {
+			return 0, err
+		}
+
+		for _, hookID := range hookIDs {
+			deliveryID, _ := uuid.NewV7()
+			_, err := tx.Exec(ctx,
+				`INSERT INTO webhook_deliveries (id, webhook_id, event_id, type, payload)
+				 VALUES ($1, $2, $3, $4, $5)`,
+				deliveryID, hookID, e.ID, e.Type, payload,
+			)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE webhook_outbox SET processed_at = NOW() WHERE id = ANY($1)", ids)
+	if err != nil {
+		return 0, err
+	}
+	return len(events), tx.Commit(ctx)
+}
+
+type dueDelivery struct {
+	id       string
+	typ      string
+	payload  []byte
+	attempts int
+	url      string
+	secret   string
+	active   bool
+}
+
+// deliverDue claims deliveries whose next attempt is due and sends them.
+func (w *Worker) deliverDue(ctx context.Context) (int, error) {
+	rows, err := w.db.Query(ctx,
+		`UPDATE webhook_deliveries d
+		 SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		 FROM group_webhooks wh
+		 WHERE wh.id = d.webhook_id
+		 AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING d.id::text, d.type, d.payload, d.attempts, wh.url, wh.secret, wh.active`,
+		deliverBatch, claimLease.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	due := make([]dueDelivery, 0)
+	for rows.Next() {
+		var d dueDelivery
+		if err := rows.Scan(&d.id, &d.typ, &d.payload, &d.attempts, &d.url, &d.secret, &d.active); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.attempt(ctx, d); err != nil {
+				log.Printf("Failed to record webhook delivery %s: %v", d.id, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+// attempt sends one delivery and records the outcome.
+func (w *Worker) attempt(ctx context.Context, d dueDelivery) error {
+	if !d.active {
+		_, err := w.db.Exec(ctx,
+			`UPDATE webhook_deliveries SET status = 'failed', last_error = 'webhook disabled'
+			 WHERE id = $1`,
+			d.id,
+		)
+		return err
+	}
+
+	result, sendErr := w.client.Post(ctx, d.url, d.secret, d.typ, d.id, d.payload)
+	attempts := d.attempts + 1
+
+	var statusCode *int
+	if result.StatusCode != 0 {
+		statusCode = &result.StatusCode
+	}
+
+	if sendErr == nil {
+		_, err := w.db.Exec(ctx,
+			`UPDATE webhook_deliveries SET status = 'succeeded', attempts = $2,
+				last_attempt_at = NOW(), delivered_at = NOW(),
+				last_status_code = $3, last_response = $4, last_error = NULL
+			 WHERE id = $1`,
+			d.id, attempts, statusCode, result.Excerpt,
+		)
+		return err
+	}
+
+	status := "pending"
+	if attempts >= MaxAttempts {
+		status = "failed"
+	}
+	_, err := w.db.Exec(ctx,
+		`UPDATE webhook_deliveries SET status = $2, attempts = $3,
+			last_attempt_at = NOW(), next_attempt_at = NOW() + $4 * INTERVAL '1 second',
+			last_status_code = $5, last_response = $6, last_error = $7
+		 WHERE id = $1`,
+		d.id, status, attempts, Backoff(attempts).Seconds(), statusCode, result.Excerpt, sendErr.Error(),
+	)
+	return err
+}
+
+func (w *Worker) prune(ctx context.Context, now time.Time) error {
+	_, err := w.db.Exec(ctx,
+		"DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1",
+		now.Add(-deliveryRetention),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = w.db.Exec(ctx,
+		"DELETE FROM webhook_outbox WHERE processed_at < $1",
+		now.Add(-outboxRetention),
+	)
+	return err
+}
+
+// Run processes the outbox and due deliveries until ctx ends.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	lastPrune := time.Time{}
+	for {
+		// drain full batches before waiting for the next tick
+		for {
+			n, err := w.fanOut(ctx)
+			if err != nil {
+				log.Printf("Webhook fan out failed: %v", err)
+			}
+			if err != nil || n < fanOutBatch {
+				break
+			}
+		}
+		for {
+			n, err := w.deliverDue(ctx)
+			if err != nil {
+				log.Printf("Webhook delivery failed: %v", err)
+			}
+			if err != nil || n < deliverBatch {
+				break
+			}
+		}
+
+		now := time.Now()
+		if now.Sub(lastPrune) > time.Hour {
+			if err := w.prune(ctx, now); err != nil {
+				log.Printf("Webhook prune failed: %v", err)
+			}
+			lastPrune = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
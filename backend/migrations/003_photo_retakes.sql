@@ -0,0 +1,6 @@
+ALTER TABLE photos
+    ADD COLUMN IF NOT EXISTS retake_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS retaken_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE groups
+    ADD COLUMN IF NOT EXISTS show_retakes BOOLEAN NOT NULL DEFAULT FALSE;
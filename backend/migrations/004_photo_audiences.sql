@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS close_friends (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    friend_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, friend_id),
+    CONSTRAINT close_friend_not_self CHECK (user_id <> friend_id)
+);
+
+CREATE TABLE IF NOT EXISTS photo_audiences (
+    photo_id UUID PRIMARY KEY REFERENCES photos(id) ON DELETE CASCADE,
+    audience TEXT NOT NULL DEFAULT 'all' CHECK (audience IN ('all', 'groups', 'close_friends')),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS photo_audience_groups (
+    photo_id UUID NOT NULL REFERENCES photo_audiences(photo_id) ON DELETE CASCADE,
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    PRIMARY KEY (photo_id, group_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_photo_audience_groups_group ON photo_audience_groups(group_id);
+
+-- photos confirmed before audiences existed stay visible everywhere
+INSERT INTO photo_audiences (photo_id, audience)
+SELECT id, 'all' FROM photos
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,22 @@
+ALTER TABLE photos
+    ADD COLUMN IF NOT EXISTS caption TEXT;
+
+CREATE TABLE IF NOT EXISTS photo_reactions (
+    photo_id UUID NOT NULL REFERENCES photos(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (photo_id, user_id, emoji)
+);
+
+CREATE TABLE IF NOT EXISTS photo_comments (
+    id UUID PRIMARY KEY,
+    photo_id UUID NOT NULL REFERENCES photos(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES photo_comments(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_photo_comments_photo ON photo_comments(photo_id, created_at);
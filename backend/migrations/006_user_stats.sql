@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS user_stats (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    current_streak INT NOT NULL DEFAULT 0,
+    longest_streak INT NOT NULL DEFAULT 0,
+    last_slot TIMESTAMP WITH TIME ZONE,
+    total_captured INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS user_daily_stats (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    captured INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+);
+
+-- backfill from photos confirmed before stats were tracked
+INSERT INTO user_daily_stats (user_id, day, captured)
+SELECT user_id, (hour_timestamp AT TIME ZONE 'UTC')::date, COUNT(*)
+FROM photos
+GROUP BY user_id, (hour_timestamp AT TIME ZONE 'UTC')::date
+ON CONFLICT DO NOTHING;
+
+WITH ordered AS (
+    SELECT user_id, hour_timestamp,
+        hour_timestamp - (ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY hour_timestamp)) * INTERVAL '1 hour' AS island
+    FROM photos
+), islands AS (
+    SELECT user_id, COUNT(*) AS len, MAX(hour_timestamp) AS last_slot
+    FROM ordered
+    GROUP BY user_id, island
+), ranked AS (
+    SELECT *, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY last_slot DESC) AS rn
+    FROM islands
+)
+INSERT INTO user_stats (user_id, current_streak, longest_streak, last_slot, total_captured)
+SELECT user_id, MAX(len) FILTER (WHERE rn = 1), MAX(len), MAX(last_slot), SUM(len)
+FROM ranked
+GROUP BY user_id
+ON CONFLICT DO NOTHING;
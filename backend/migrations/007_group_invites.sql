@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS group_invites (
+    id UUID PRIMARY KEY,
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    code TEXT UNIQUE NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    invitee_id UUID REFERENCES users(id) ON DELETE CASCADE, -- set for direct invitations
+    max_uses INT CHECK (max_uses IS NULL OR max_uses > 0),
+    use_count INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_invites_group ON group_invites(group_id);
+CREATE INDEX IF NOT EXISTS idx_group_invites_invitee ON group_invites(invitee_id) WHERE invitee_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS group_invite_redemptions (
+    invite_id UUID NOT NULL REFERENCES group_invites(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    redeemed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (invite_id, user_id)
+);
@@ -0,0 +1,12 @@
+ALTER TABLE group_members
+    ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+    ADD COLUMN IF NOT EXISTS joined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+UPDATE group_members gm
+SET role = 'owner'
+FROM groups g
+WHERE g.id = gm.group_id AND g.owner_id = gm.user_id;
+
+-- groups.owner_id is kept in sync with the single owner row
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_members_one_owner
+ON group_members (group_id) WHERE role = 'owner';
@@ -0,0 +1,5 @@
+ALTER TABLE groups
+    ADD COLUMN IF NOT EXISTS description TEXT,
+    ADD COLUMN IF NOT EXISTS avatar_key TEXT,
+    ADD COLUMN IF NOT EXISTS member_limit INT CHECK (member_limit IS NULL OR member_limit > 0),
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
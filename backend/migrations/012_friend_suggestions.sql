@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS friend_suggestion_dismissals (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dismissed_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dismissed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, dismissed_id)
+);
+
+-- suggestions walk accepted friendships from both sides of the canonical pair
+CREATE INDEX IF NOT EXISTS idx_friendships_accepted_a ON friendships(user_a_id) WHERE status = 'accepted';
+CREATE INDEX IF NOT EXISTS idx_friendships_accepted_b ON friendships(user_b_id) WHERE status = 'accepted';
+CREATE INDEX IF NOT EXISTS idx_group_members_user ON group_members(user_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS friend_codes (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code TEXT UNIQUE NOT NULL,
+    single_use BOOLEAN NOT NULL DEFAULT FALSE,
+    used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- at most one usable code per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_friend_codes_active_user
+ON friend_codes (user_id) WHERE revoked_at IS NULL AND used_at IS NULL;
@@ -0,0 +1,13 @@
+ALTER TABLE friendships
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS message TEXT CHECK (char_length(message) <= 140);
+
+CREATE INDEX IF NOT EXISTS idx_friendships_pending_created ON friendships(created_at) WHERE status = 'pending';
+
+-- when a request was last rejected, to hold off repeat requests
+CREATE TABLE IF NOT EXISTS friend_request_rejections (
+    rejector_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    requester_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rejected_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (rejector_id, requester_id)
+);
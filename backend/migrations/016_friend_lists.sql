@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS friend_lists (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_friend_lists_user_name ON friend_lists (user_id, lower(name));
+
+CREATE TABLE IF NOT EXISTS friend_list_members (
+    list_id UUID NOT NULL REFERENCES friend_lists(id) ON DELETE CASCADE,
+    friend_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (list_id, friend_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_friend_list_members_friend ON friend_list_members(friend_id);
+
+ALTER TABLE photo_audiences DROP CONSTRAINT IF EXISTS photo_audiences_audience_check;
+ALTER TABLE photo_audiences
+    ADD CONSTRAINT photo_audiences_audience_check CHECK (audience IN ('all', 'groups', 'close_friends', 'lists'));
+
+CREATE TABLE IF NOT EXISTS photo_audience_lists (
+    photo_id UUID NOT NULL REFERENCES photo_audiences(photo_id) ON DELETE CASCADE,
+    list_id UUID NOT NULL REFERENCES friend_lists(id) ON DELETE CASCADE,
+    PRIMARY KEY (photo_id, list_id)
+);
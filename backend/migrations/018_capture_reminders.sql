@@ -0,0 +1,9 @@
+-- one row per reminder send; the primary key lets exactly one instance claim each run
+CREATE TABLE IF NOT EXISTS reminder_runs (
+    slot TIMESTAMP WITH TIME ZONE NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('open', 'last_chance')),
+    claimed_by TEXT NOT NULL,
+    claimed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    recipients INT,
+    PRIMARY KEY (slot, kind)
+);
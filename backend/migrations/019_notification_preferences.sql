@@ -0,0 +1,22 @@
+-- a missing row means every category is on and there are no quiet hours
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    friend_requests BOOLEAN NOT NULL DEFAULT TRUE,
+    group_invites BOOLEAN NOT NULL DEFAULT TRUE,
+    new_photos BOOLEAN NOT NULL DEFAULT TRUE,
+    reminders BOOLEAN NOT NULL DEFAULT TRUE,
+    comments BOOLEAN NOT NULL DEFAULT TRUE,
+    reactions BOOLEAN NOT NULL DEFAULT TRUE,
+    quiet_hours_start TIME,
+    quiet_hours_end TIME,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT quiet_hours_pair CHECK ((quiet_hours_start IS NULL) = (quiet_hours_end IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS group_notification_settings (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    muted BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, group_id)
+);
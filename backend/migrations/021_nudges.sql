@@ -0,0 +1,13 @@
+-- one row per sender, recipient and slot, which is also the rate limit
+CREATE TABLE IF NOT EXISTS nudges (
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    slot TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (sender_id, recipient_id, slot)
+);
+
+CREATE INDEX IF NOT EXISTS idx_nudges_group_created ON nudges(group_id, created_at);
+
+ALTER TABLE notification_preferences ADD COLUMN IF NOT EXISTS nudges BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,13 @@
+-- recent group activity replayed to live streams; rows are pruned after a day
+CREATE TABLE IF NOT EXISTS feed_events (
+    id BIGSERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- membership events are scoped to a group, photo events to who can see the photo
+    group_id UUID REFERENCES groups(id) ON DELETE CASCADE,
+    photo_id UUID REFERENCES photos(id) ON DELETE CASCADE,
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_feed_events_created ON feed_events(created_at);
@@ -0,0 +1,14 @@
+-- the digest is email, so it stays off until the user turns it on
+ALTER TABLE notification_preferences ADD COLUMN IF NOT EXISTS daily_digest BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- one digest per user and local day, claimed before sending so only one instance sends it
+CREATE TABLE IF NOT EXISTS digest_runs (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    claimed_by TEXT NOT NULL,
+    claimed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    status TEXT NOT NULL DEFAULT 'claimed' CHECK (status IN ('claimed', 'sent', 'empty', 'failed')),
+    error TEXT,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (user_id, day)
+);
@@ -0,0 +1,50 @@
+-- close friends become a built-in friend list instead of a table of their own
+ALTER TABLE friend_lists ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'custom'
+    CHECK (kind IN ('custom', 'close_friends'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_friend_lists_user_close_friends ON friend_lists (user_id)
+    WHERE kind = 'close_friends';
+
+DO $$
+BEGIN
+    IF to_regclass('close_friends') IS NULL THEN
+        RETURN;
+    END IF;
+
+    -- a custom list already called Close Friends takes over the role
+    UPDATE friend_lists SET kind = 'close_friends'
+    WHERE lower(name) = 'close friends' AND kind = 'custom';
+
+    INSERT INTO friend_lists (id, user_id, name, kind)
+    SELECT gen_random_uuid(), owners.user_id, 'Close Friends', 'close_friends'
+    FROM (
+        SELECT user_id FROM close_friends
+        UNION
+        SELECT p.user_id FROM photo_audiences pa
+        JOIN photos p ON p.id = pa.photo_id
+        WHERE pa.audience = 'close_friends'
+    ) owners
+    ON CONFLICT DO NOTHING;
+
+    INSERT INTO friend_list_members (list_id, friend_id, added_at)
+    SELECT fl.id, cf.friend_id, cf.created_at
+    FROM close_friends cf
+    JOIN friend_lists fl ON fl.user_id = cf.user_id AND fl.kind = 'close_friends'
+    ON CONFLICT DO NOTHING;
+
+    INSERT INTO photo_audience_lists (photo_id, list_id)
+    SELECT pa.photo_id, fl.id
+    FROM photo_audiences pa
+    JOIN photos p ON p.id = pa.photo_id
+    JOIN friend_lists fl ON fl.user_id = p.user_id AND fl.kind = 'close_friends'
+    WHERE pa.audience = 'close_friends'
+    ON CONFLICT DO NOTHING;
+
+    UPDATE photo_audiences SET audience = 'lists' WHERE audience = 'close_friends';
+
+    DROP TABLE close_friends;
+END $$;
+
+ALTER TABLE photo_audiences DROP CONSTRAINT IF EXISTS photo_audiences_audience_check;
+ALTER TABLE photo_audiences
+    ADD CONSTRAINT photo_audiences_audience_check CHECK (audience IN ('all', 'groups', 'lists'));
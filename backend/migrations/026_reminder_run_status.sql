@@ -0,0 +1,6 @@
+-- runs from before statuses existed all got sent
+ALTER TABLE reminder_runs ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'sent'
+    CHECK (status IN ('claimed', 'sent', 'failed'));
+ALTER TABLE reminder_runs ALTER COLUMN status SET DEFAULT 'claimed';
+ALTER TABLE reminder_runs ADD COLUMN IF NOT EXISTS error TEXT;
+ALTER TABLE reminder_runs ADD COLUMN IF NOT EXISTS finished_at TIMESTAMP WITH TIME ZONE;
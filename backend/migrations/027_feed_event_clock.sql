@@ -0,0 +1,3 @@
+-- NOW() is when the inserting transaction began; gap detection needs when the row,
+-- and so its ID, was actually created
+ALTER TABLE feed_events ALTER COLUMN created_at SET DEFAULT clock_timestamp();
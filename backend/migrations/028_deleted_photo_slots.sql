@@ -0,0 +1,8 @@
+-- a photo deleted while its window is open uses up the slot, otherwise deleting and
+-- confirming again would get around the retake limit
+CREATE TABLE IF NOT EXISTS deleted_photo_slots (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hour_timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, hour_timestamp)
+);
@@ -0,0 +1,15 @@
+-- every user has the built-in close friends list, created at sign-up from now on.
+-- A custom list already called Close Friends takes over the role.
+UPDATE friend_lists fl SET kind = 'close_friends'
+WHERE fl.kind = 'custom' AND lower(fl.name) = 'close friends'
+AND NOT EXISTS (
+    SELECT 1 FROM friend_lists other WHERE other.user_id = fl.user_id AND other.kind = 'close_friends'
+);
+
+INSERT INTO friend_lists (id, user_id, name, kind)
+SELECT gen_random_uuid(), u.id, 'Close Friends', 'close_friends'
+FROM users u
+WHERE NOT EXISTS (
+    SELECT 1 FROM friend_lists fl WHERE fl.user_id = u.id AND fl.kind = 'close_friends'
+)
+ON CONFLICT DO NOTHING;
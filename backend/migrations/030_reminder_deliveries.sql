@@ -0,0 +1,9 @@
+-- who a reminder run already reached, so a retried run only sends to the rest
+CREATE TABLE IF NOT EXISTS reminder_deliveries (
+    slot TIMESTAMP WITH TIME ZONE NOT NULL,
+    kind TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    delivered_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (slot, kind, user_id),
+    FOREIGN KEY (slot, kind) REFERENCES reminder_runs (slot, kind) ON DELETE CASCADE
+);